@@ -0,0 +1,150 @@
+// Package acl allows or denies the ips of clients with the cidr rules
+// of the acl section, the rules are read again for every check so they
+// change with a reload
+package acl
+
+import (
+	"expvar"
+	"net"
+	"strings"
+
+	"github.com/alipourhabibi/restream/settings"
+)
+
+// Actions which have their own rules
+const (
+	Publish = "publish"
+	Play    = "play"
+	Admin   = "admin"
+)
+
+// metrics of the rejected ips which are served in /debug/vars
+var metrics = expvar.NewMap("acl")
+
+// rule matches the ips of network for an app, every app when app is empty
+type rule struct {
+	app     string
+	network *net.IPNet
+}
+
+// parse reads comma separated rules like 10.0.0.0/8 or live=10.0.0.0/8
+// a single ip is its own network and the invalid rules are skipped
+// as they're reported by the validation of the settings
+func parse(list string) []rule {
+	var rules []rule
+	for _, r := range strings.Split(list, ",") {
+		r = strings.TrimSpace(r)
+		if r == "" {
+			continue
+		}
+		var app string
+		if i := strings.Index(r, "="); i >= 0 {
+			app, r = strings.TrimSpace(r[:i]), strings.TrimSpace(r[i+1:])
+			if app == "*" {
+				app = ""
+			}
+		}
+		network, err := Network(r)
+		if err != nil {
+			continue
+		}
+		rules = append(rules, rule{app: app, network: network})
+	}
+	return rules
+}
+
+// Network parses a cidr or a single ip
+func Network(s string) (*net.IPNet, error) {
+	if !strings.Contains(s, "/") {
+		if ip := net.ParseIP(s); ip != nil {
+			if ip.To4() != nil {
+				s += "/32"
+			} else {
+				s += "/128"
+			}
+		}
+	}
+	_, network, err := net.ParseCIDR(s)
+	return network, err
+}
+
+// rules returns the allow and deny rules of an action
+func rules(action string) ([]rule, []rule) {
+	items := settings.ACLSettings().Items
+	switch action {
+	case Publish:
+		return parse(items.PublishAllow), parse(items.PublishDeny)
+	case Play:
+		return parse(items.PlayAllow), parse(items.PlayDeny)
+	}
+	return parse(items.AdminAllow), parse(items.AdminDeny)
+}
+
+// allowed reports whether ip can do action in app, the deny rules
+// come first and then ip must match an allow rule when app has any
+func allowed(action, app string, ip net.IP) bool {
+	allow, deny := rules(action)
+	for _, r := range deny {
+		if (r.app == "" || r.app == app) && r.network.Contains(ip) {
+			return false
+		}
+	}
+	restricted := false
+	for _, r := range allow {
+		if r.app == "" || r.app == app {
+			restricted = true
+			if r.network.Contains(ip) {
+				return true
+			}
+		}
+	}
+	return !restricted
+}
+
+// anyApp reports whether ip may do action in some app, only the rules
+// of every app can reject it before the app is known
+func anyApp(action string, ip net.IP) bool {
+	allow, deny := rules(action)
+	for _, r := range deny {
+		if r.app == "" && r.network.Contains(ip) {
+			return false
+		}
+	}
+	restricted := false
+	for _, r := range allow {
+		if r.network.Contains(ip) {
+			return true
+		}
+		restricted = restricted || r.app == ""
+	}
+	return !restricted
+}
+
+// Allowed reports whether the client at addr, an ip or host:port,
+// can do action in app and counts it when it can't
+func Allowed(action, app, addr string) bool {
+	ip := parseIP(addr)
+	if ip != nil && allowed(action, app, ip) {
+		return true
+	}
+	metrics.Add(action, 1)
+	return false
+}
+
+// Accepted reports whether the client at addr may publish or play in
+// some app, it's checked right after accepting its connection
+func Accepted(addr string) bool {
+	ip := parseIP(addr)
+	if ip != nil && (anyApp(Publish, ip) || anyApp(Play, ip)) {
+		return true
+	}
+	metrics.Add("accept", 1)
+	return false
+}
+
+func parseIP(addr string) net.IP {
+	if host, _, err := net.SplitHostPort(addr); err == nil {
+		addr = host
+	}
+	return net.ParseIP(addr)
+}
@@ -0,0 +1,123 @@
+package acl
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/alipourhabibi/restream/settings"
+)
+
+// loadRules replaces the settings with the acl section of conf
+// and the files the other sections need to be valid
+func loadRules(t *testing.T, conf string) {
+	t.Helper()
+	dir := t.TempDir()
+	services := filepath.Join(dir, "servers.json")
+	if err := os.WriteFile(services, []byte("{}"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(dir, "conf.ini")
+	conf = "[server]\nServices = " + services + "\n[auth]\nBackend = none\n[acl]\n" + conf
+	if err := os.WriteFile(path, []byte(conf), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := settings.Load(path); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestAllowed(t *testing.T) {
+	loadRules(t, `
+PublishAllow = 10.0.0.0/8, live=192.168.1.0/24, *=172.16.0.1
+PublishDeny = 10.0.0.66, vod=10.1.0.0/16
+PlayAllow = vod=10.0.0.0/8
+PlayDeny = 2001:db8::/32
+AdminAllow = 127.0.0.1, ::1
+`)
+
+	tests := []struct {
+		action, app, addr string
+		want              bool
+	}{
+		{Publish, "live", "10.2.3.4:5000", true},
+		{Publish, "live", "192.168.1.7:5000", true},
+		{Publish, "vod", "192.168.1.7:5000", false},
+		{Publish, "vod", "172.16.0.1", true},
+		{Publish, "live", "10.0.0.66:5000", false},
+		{Publish, "vod", "10.1.2.3:5000", false},
+		{Publish, "live", "10.1.2.3:5000", true},
+		{Publish, "live", "8.8.8.8:5000", false},
+		{Publish, "live", "not an ip", false},
+
+		// only vod has allow rules for play
+		{Play, "vod", "10.2.3.4:5000", true},
+		{Play, "vod", "8.8.8.8:5000", false},
+		{Play, "live", "8.8.8.8:5000", true},
+		{Play, "live", "[2001:db8::1]:5000", false},
+		{Play, "live", "[2001:db9::1]:5000", true},
+
+		{Admin, "", "127.0.0.1:8080", true},
+		{Admin, "", "[::1]:8080", true},
+		{Admin, "", "10.2.3.4:8080", false},
+	}
+	for _, tt := range tests {
+		if got := Allowed(tt.action, tt.app, tt.addr); got != tt.want {
+			t.Errorf("Allowed(%s, %s, %s) = %v, want %v", tt.action, tt.app, tt.addr, got, tt.want)
+		}
+	}
+}
+
+func TestAccepted(t *testing.T) {
+	loadRules(t, `
+PublishAllow = live=10.0.0.0/8, 172.16.0.0/12
+PublishDeny = 10.0.0.66
+PlayAllow = 192.168.0.0/16
+PlayDeny = 10.0.0.66, vod=10.0.0.67
+`)
+
+	tests := []struct {
+		addr string
+		want bool
+	}{
+		// a publisher of an app with its own rules
+		{"10.1.2.3:5000", true},
+		{"192.168.1.1:5000", true},
+		// denied in every app for both actions
+		{"10.0.0.66:5000", false},
+		// only denied in vod so it may still play elsewhere
+		{"10.0.0.67:5000", true},
+		// it matches no allow rule and every app has some
+		{"8.8.8.8:5000", false},
+	}
+	for _, tt := range tests {
+		if got := Accepted(tt.addr); got != tt.want {
+			t.Errorf("Accepted(%s) = %v, want %v", tt.addr, got, tt.want)
+		}
+	}
+}
+
+func TestNetwork(t *testing.T) {
+	tests := []struct {
+		in   string
+		want string
+	}{
+		{"10.0.0.0/8", "10.0.0.0/8"},
+		{"10.1.2.3/8", "10.0.0.0/8"},
+		{"10.1.2.3", "10.1.2.3/32"},
+		{"::1", "::1/128"},
+		{"2001:db8::/32", "2001:db8::/32"},
+		{"10.0.0.300", ""},
+		{"", ""},
+	}
+	for _, tt := range tests {
+		network, err := Network(tt.in)
+		got := ""
+		if err == nil {
+			got = network.String()
+		}
+		if got != tt.want {
+			t.Errorf("Network(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
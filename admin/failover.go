@@ -0,0 +1,13 @@
+package admin
+
+import "net/http"
+
+// handleFailover lists the stream keys with a backup publisher,
+// which of them is active and when they last switched
+func (s *Server) handleFailover(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeError(w, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+	writeJSON(w, http.StatusOK, s.stream.Failovers())
+}
@@ -0,0 +1,29 @@
+package admin
+
+import (
+	"net/http"
+
+	"github.com/alipourhabibi/restream/settings"
+)
+
+// handleReload reloads the config and the services catalog like SIGHUP
+// and lists the changed keys and whether they need a restart
+func (s *Server) handleReload(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeError(w, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+	if s.Reload == nil {
+		writeError(w, http.StatusNotImplemented, "reload is not available")
+		return
+	}
+	changes, err := s.Reload()
+	if err != nil {
+		writeError(w, http.StatusUnprocessableEntity, err.Error())
+		return
+	}
+	if changes == nil {
+		changes = []settings.Change{}
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{"changes": changes})
+}
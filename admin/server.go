@@ -0,0 +1,100 @@
+package admin
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"expvar"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"sync"
+
+	"github.com/alipourhabibi/restream/acl"
+	"github.com/alipourhabibi/restream/rtmp"
+	"github.com/alipourhabibi/restream/settings"
+)
+
+// Server is the http api for managing the running server
+type Server struct {
+	log     *log.Logger
+	stream  *rtmp.Stream
+	mux     *http.ServeMux
+	mu      sync.Mutex
+	sources map[string]*fileSource
+	// Reload reloads the config, it's set by main
+	Reload func() ([]settings.Change, error)
+}
+
+// NewServer returns the admin api server with its routes registered
+func NewServer(log *log.Logger, stream *rtmp.Stream) *Server {
+	s := &Server{
+		log:     log,
+		stream:  stream,
+		mux:     http.NewServeMux(),
+		sources: make(map[string]*fileSource),
+	}
+	// the sources read files from the disk of the server so they're never open to everyone
+	if settings.AdminSettings().Items.Token != "" {
+		s.mux.HandleFunc("/api/sources", s.authorize(s.handleSources))
+		s.mux.HandleFunc("/api/sources/", s.authorize(s.handleSource))
+	} else {
+		log.Println("[WARNING] the sources api is disabled because the admin Token isn't set")
+	}
+	s.mux.HandleFunc("/api/failover", s.authorize(s.handleFailover))
+	s.mux.HandleFunc("/api/streams", s.authorize(s.handleStreams))
+	s.mux.HandleFunc("/api/reload", s.authorize(s.handleReload))
+	s.mux.HandleFunc("/debug/vars", s.authorize(expvar.Handler().ServeHTTP))
+	return s
+}
+
+// ListenAndServe starts the api on the host and port of the admin section
+// only localhost can use it without a token
+func (s *Server) ListenAndServe() error {
+	items := settings.AdminSettings().Items
+	if items.Token == "" && !isLoopback(items.Host) {
+		return fmt.Errorf("admin api needs a Token to listen on %s", items.Host)
+	}
+	addr := fmt.Sprintf("%s:%d", items.Host, items.Port)
+	s.log.Println("admin api listening on", addr)
+	return http.ListenAndServe(addr, s.mux)
+}
+
+// authorize rejects requests from the ips the acl section doesn't allow
+// and the ones without the configured bearer token
+func (s *Server) authorize(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if !acl.Allowed(acl.Admin, "", r.RemoteAddr) {
+			s.log.Printf("[WARNING] %s isn't allowed to use the admin api\n", r.RemoteAddr)
+			writeError(w, http.StatusForbidden, "forbidden")
+			return
+		}
+		token := settings.AdminSettings().Items.Token
+		if token != "" {
+			got := r.Header.Get("Authorization")
+			if subtle.ConstantTimeCompare([]byte(got), []byte("Bearer "+token)) != 1 {
+				writeError(w, http.StatusUnauthorized, "unauthorized")
+				return
+			}
+		}
+		next(w, r)
+	}
+}
+
+func isLoopback(host string) bool {
+	if host == "localhost" {
+		return true
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+func writeError(w http.ResponseWriter, status int, message string) {
+	writeJSON(w, status, map[string]string{"error": message})
+}
@@ -0,0 +1,135 @@
+package admin
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/alipourhabibi/restream/settings"
+	"github.com/alipourhabibi/restream/source"
+)
+
+// fileSource is a flv file which is being published as a live stream
+type fileSource struct {
+	ID      string    `json:"id"`
+	App     string    `json:"app"`
+	File    string    `json:"file"`
+	Loop    bool      `json:"loop"`
+	Started time.Time `json:"started"`
+	key     string
+	cancel  context.CancelFunc
+}
+
+type sourceRequest struct {
+	App  string `json:"app"`
+	Key  string `json:"key"`
+	File string `json:"file"`
+	Loop bool   `json:"loop"`
+}
+
+// handleSources lists the file sources on GET and starts a new one on POST
+func (s *Server) handleSources(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		s.mu.Lock()
+		sources := make([]*fileSource, 0, len(s.sources))
+		for _, src := range s.sources {
+			sources = append(sources, src)
+		}
+		s.mu.Unlock()
+		writeJSON(w, http.StatusOK, sources)
+	case http.MethodPost:
+		req := sourceRequest{}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			writeError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		if req.App == "" || req.Key == "" || req.File == "" {
+			writeError(w, http.StatusBadRequest, "app, key and file are required")
+			return
+		}
+		file, err := sourceFile(req.File)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		req.File = file
+		src := s.startSource(req)
+		writeJSON(w, http.StatusCreated, src)
+	default:
+		writeError(w, http.StatusMethodNotAllowed, "method not allowed")
+	}
+}
+
+// handleSource stops the file source with the id in the path on DELETE
+func (s *Server) handleSource(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodDelete {
+		writeError(w, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+	id := strings.TrimPrefix(r.URL.Path, "/api/sources/")
+	s.mu.Lock()
+	src, ok := s.sources[id]
+	s.mu.Unlock()
+	if !ok {
+		writeError(w, http.StatusNotFound, "source not found")
+		return
+	}
+	src.cancel()
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// sourceFile returns the path of name in SourcesDir of the admin section
+// names which would escape it are rejected
+func sourceFile(name string) (string, error) {
+	dir := settings.AdminSettings().Items.SourcesDir
+	path := filepath.Join(dir, filepath.Clean("/"+name))
+	rel, err := filepath.Rel(dir, path)
+	if err != nil || rel == "." || strings.HasPrefix(rel, "..") {
+		return "", fmt.Errorf("file %q is not in the sources directory", name)
+	}
+	if info, err := os.Stat(path); err != nil || info.IsDir() {
+		return "", fmt.Errorf("file %q is not found in the sources directory", name)
+	}
+	return path, nil
+}
+
+// startSource publishes the file to this server in the background
+// the source is removed when publishing ends
+func (s *Server) startSource(req sourceRequest) *fileSource {
+	ctx, cancel := context.WithCancel(context.Background())
+	id := make([]byte, 8)
+	rand.Read(id)
+	src := &fileSource{
+		ID:      hex.EncodeToString(id),
+		App:     req.App,
+		File:    req.File,
+		Loop:    req.Loop,
+		Started: time.Now(),
+		key:     req.Key,
+		cancel:  cancel,
+	}
+	s.mu.Lock()
+	s.sources[src.ID] = src
+	s.mu.Unlock()
+
+	url := fmt.Sprintf("rtmp://127.0.0.1:%d/%s/%s", settings.ServerSettings().Items.Port, src.App, src.key)
+	go func() {
+		err := source.PublishFLV(ctx, s.log, src.File, url, src.Loop)
+		if err != nil && err != context.Canceled {
+			s.log.Println("[ERROR] publishing", src.File, err.Error())
+		}
+		s.mu.Lock()
+		delete(s.sources, src.ID)
+		s.mu.Unlock()
+		cancel()
+	}()
+	return src
+}
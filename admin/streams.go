@@ -0,0 +1,13 @@
+package admin
+
+import "net/http"
+
+// handleStreams lists the published streams with their players,
+// destinations and the stats of their media
+func (s *Server) handleStreams(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeError(w, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+	writeJSON(w, http.StatusOK, s.stream.Streams())
+}
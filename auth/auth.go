@@ -0,0 +1,103 @@
+package auth
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"time"
+
+	"github.com/alipourhabibi/restream/logging"
+	"github.com/alipourhabibi/restream/settings"
+)
+
+// Actions a client can ask to be authorized for
+const (
+	ActionPublish = "publish"
+	ActionPlay    = "play"
+)
+
+// Request is what a publisher or player is authorized with
+// Query is what came after ? in the stream name and Backup is set
+// when the key of a publisher had the BackupSuffix of failover
+type Request struct {
+	Action string `json:"action"`
+	App    string `json:"app"`
+	Key    string `json:"key"`
+	Query  string `json:"query,omitempty"`
+	IP     string `json:"ip"`
+	Backup bool   `json:"backup,omitempty"`
+}
+
+// Destination is a server the stream is sent to with its key in the url
+type Destination struct {
+	Name string `json:"name" yaml:"name"`
+	URL  string `json:"url" yaml:"url"`
+}
+
+// Result is the answer of an Authenticator
+// Twitch, Youtube and Aparat are the keys for the servers in services/servers.json
+// Play is the public name players use for the stream instead of its key
+type Result struct {
+	Allowed      bool          `json:"allowed" yaml:"allowed"`
+	Record       bool          `json:"record" yaml:"record"`
+	Backup       bool          `json:"backup" yaml:"backup"`
+	Play         string        `json:"play" yaml:"play"`
+	Twitch       string        `json:"twitch" yaml:"twitch"`
+	Youtube      string        `json:"youtube" yaml:"youtube"`
+	Aparat       string        `json:"aparat" yaml:"aparat"`
+	Destinations []Destination `json:"destinations" yaml:"destinations"`
+}
+
+// Authenticator decides whether a client can publish or play
+// and where a published stream is sent to
+type Authenticator interface {
+	Authenticate(ctx context.Context, req Request) (*Result, error)
+}
+
+// PlayName returns the public name of a stream key
+// which is used when the auth backend doesn't give one
+func PlayName(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:8])
+}
+
+// New returns the Authenticator of the backend in the auth section
+// behind a cache of its answers, it checks signed names first
+// when the token section is enabled
+func New(logs *logging.Logger) (Authenticator, error) {
+	log := logs.For(logging.Main, nil)
+	a, err := newBackend(logs)
+	if err != nil {
+		return nil, err
+	}
+	items := settings.AuthSettings().Items
+	if items.CacheTTL > 0 || items.NegativeTTL > 0 {
+		a = NewCache(a, time.Duration(items.CacheTTL)*time.Second, time.Duration(items.NegativeTTL)*time.Second)
+	}
+	token := settings.TokenSettings().Items
+	if token.Enabled {
+		if token.Secret == "" {
+			return nil, fmt.Errorf("token section is enabled without a Secret")
+		}
+		a = NewToken(log, token.Secret, token.Required, a)
+	}
+	return a, nil
+}
+
+func newBackend(logs *logging.Logger) (Authenticator, error) {
+	log := logs.For(logging.Main, nil)
+	items := settings.AuthSettings().Items
+	switch items.Backend {
+	case "", "grpc":
+		return NewGRPC(logs.For(logging.GRPC, nil))
+	case "file":
+		return NewFile(log, items.File)
+	case "webhook":
+		return NewWebhook(items.WebhookURL, items.WebhookTimeout), nil
+	case "none":
+		log.Println("[WARNING] authentication is disabled, every key can publish and play")
+		return None{}, nil
+	}
+	return nil, fmt.Errorf("unknown auth backend %q", items.Backend)
+}
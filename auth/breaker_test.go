@@ -0,0 +1,48 @@
+package auth
+
+import (
+	"testing"
+	"time"
+)
+
+func TestBreaker(t *testing.T) {
+	b := newBreaker(2, 20*time.Millisecond)
+	if !b.allow() || b.failure() {
+		t.Fatal("breaker opened after one failure")
+	}
+	if !b.allow() || !b.failure() {
+		t.Fatal("breaker didn't open after two failures")
+	}
+	if b.allow() {
+		t.Fatal("open breaker allowed a call")
+	}
+
+	time.Sleep(30 * time.Millisecond)
+	if !b.allow() {
+		t.Fatal("breaker didn't allow a probe after the cooldown")
+	}
+	if b.allow() {
+		t.Fatal("breaker allowed a second call while probing")
+	}
+	if !b.failure() || b.allow() {
+		t.Fatal("failed probe didn't open the breaker again")
+	}
+
+	time.Sleep(30 * time.Millisecond)
+	if !b.allow() {
+		t.Fatal("breaker didn't allow a probe after the second cooldown")
+	}
+	b.success()
+	if !b.allow() || !b.allow() {
+		t.Fatal("breaker isn't closed after a successful probe")
+	}
+}
+
+func TestBreakerWithoutThreshold(t *testing.T) {
+	b := newBreaker(0, time.Hour)
+	for i := 0; i < 10; i++ {
+		if !b.allow() || b.failure() {
+			t.Fatal("breaker with a threshold of 0 opened")
+		}
+	}
+}
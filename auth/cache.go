@@ -0,0 +1,74 @@
+package auth
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"time"
+)
+
+type cacheEntry struct {
+	result  Result
+	expires time.Time
+}
+
+// Cache reuses the answers of Next for a short time
+// allowed answers are kept for ttl and denied ones for negativeTTL
+// signed names are not cached as their nonces must be checked every time
+type Cache struct {
+	Next        Authenticator
+	ttl         time.Duration
+	negativeTTL time.Duration
+
+	mu      sync.Mutex
+	entries map[string]cacheEntry
+}
+
+// NewCache returns a Cache in front of next
+func NewCache(next Authenticator, ttl, negativeTTL time.Duration) *Cache {
+	return &Cache{
+		Next:        next,
+		ttl:         ttl,
+		negativeTTL: negativeTTL,
+		entries:     make(map[string]cacheEntry),
+	}
+}
+
+// Authenticate answers from the cache or asks Next
+func (c *Cache) Authenticate(ctx context.Context, req Request) (*Result, error) {
+	if req.Query != "" {
+		return c.Next.Authenticate(ctx, req)
+	}
+	key := strings.Join([]string{req.Action, req.App, req.Key, req.IP}, " ")
+	now := time.Now()
+
+	c.mu.Lock()
+	entry, ok := c.entries[key]
+	c.mu.Unlock()
+	if ok && now.Before(entry.expires) {
+		result := entry.result
+		return &result, nil
+	}
+
+	result, err := c.Next.Authenticate(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	ttl := c.ttl
+	if !result.Allowed {
+		ttl = c.negativeTTL
+	}
+	if ttl <= 0 {
+		return result, nil
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for k, e := range c.entries {
+		if now.After(e.expires) {
+			delete(c.entries, k)
+		}
+	}
+	c.entries[key] = cacheEntry{result: *result, expires: now.Add(ttl)}
+	return result, nil
+}
@@ -0,0 +1,78 @@
+package auth
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+
+	"gopkg.in/yaml.v3"
+)
+
+// fileKey is a stream key of the static file with its result
+type fileKey struct {
+	Key      string `json:"key" yaml:"key"`
+	Disabled bool   `json:"disabled" yaml:"disabled"`
+	Result   `yaml:",inline"`
+}
+
+type keysFile struct {
+	Keys []fileKey `json:"keys" yaml:"keys"`
+}
+
+// File authorizes with the keys of a static yaml or json file
+// a key which is in the file can publish and its play name can be
+// played unless it's disabled
+type File struct {
+	keys  map[string]Result
+	plays map[string]bool
+}
+
+// NewFile reads the keys of the file at path
+// files ending with .json are read as json and the others as yaml
+func NewFile(log *log.Logger, path string) (*File, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	keys := keysFile{}
+	if filepath.Ext(path) == ".json" {
+		err = json.Unmarshal(data, &keys)
+	} else {
+		err = yaml.Unmarshal(data, &keys)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", path, err)
+	}
+
+	f := &File{
+		keys:  make(map[string]Result, len(keys.Keys)),
+		plays: make(map[string]bool, len(keys.Keys)),
+	}
+	for _, k := range keys.Keys {
+		k.Allowed = !k.Disabled
+		f.keys[k.Key] = k.Result
+		play := k.Play
+		if play == "" {
+			play = PlayName(k.Key)
+		}
+		f.plays[play] = !k.Disabled
+	}
+	log.Printf("loaded %d keys from %s\n", len(f.keys), path)
+	return f, nil
+}
+
+// Authenticate looks the key of a publisher or the play name
+// of a player up in the file
+func (f *File) Authenticate(ctx context.Context, req Request) (*Result, error) {
+	if req.Action == ActionPlay {
+		return &Result{Allowed: f.plays[req.Key]}, nil
+	}
+	result, ok := f.keys[req.Key]
+	if !ok {
+		return &Result{}, nil
+	}
+	return &result, nil
+}
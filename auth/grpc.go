@@ -0,0 +1,172 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/alipourhabibi/restream/grpcclient"
+	protos "github.com/alipourhabibi/restream/protos/usersinfo"
+	"github.com/alipourhabibi/restream/settings"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+var errBreakerOpen = errors.New("users info service is failing, not calling it for now")
+
+// GRPC authorizes with the UsersInfo service
+// calls have a deadline and are retried when the service is unavailable
+// after too many failures it stops calling the service for a while and
+// with the lastgood policy answers with the last result of the key
+type GRPC struct {
+	log     *log.Logger
+	RPC     protos.UsersInfoClient
+	timeout time.Duration
+	retries int
+	backoff time.Duration
+	policy  string
+	breaker *breaker
+	// players are only asked about when it's set
+	playAuth bool
+
+	mu          sync.Mutex
+	lastGood    map[string]cacheEntry
+	lastGoodTTL time.Duration
+}
+
+// NewGRPC returns a GRPC authenticator connected to the grpcusersinfo section
+func NewGRPC(log *log.Logger) (*GRPC, error) {
+	items := settings.GRPCUsersInfoSettings().Items
+	usersInfo := grpcclient.NewUsersInfo(log)
+	client, err := usersInfo.GetClient()
+	if err != nil {
+		return nil, err
+	}
+	g := &GRPC{
+		log:      log,
+		RPC:      client,
+		timeout:  time.Duration(items.Timeout) * time.Millisecond,
+		retries:  items.Retries,
+		backoff:  time.Duration(items.RetryBackoff) * time.Millisecond,
+		policy:   items.BreakerPolicy,
+		breaker:  newBreaker(items.BreakerFailures, time.Duration(items.BreakerCooldown)*time.Second),
+		playAuth: items.PlayAuth,
+		lastGood: make(map[string]cacheEntry),
+	}
+	g.lastGoodTTL = time.Duration(items.LastGoodTTL) * time.Second
+	if g.timeout <= 0 {
+		g.timeout = 3 * time.Second
+	}
+
+	if items.HealthCheck {
+		ctx, cancel := context.WithTimeout(context.Background(), g.timeout)
+		defer cancel()
+		if err := usersInfo.CheckHealth(ctx); err != nil {
+			log.Println("[WARNING] users info health check failed: " + err.Error())
+		} else {
+			log.Println("users info service is serving")
+		}
+	}
+	return g, nil
+}
+
+// Authenticate asks the service about the key of a publisher and with
+// PlayAuth about the play name of a player, the others are allowed
+// as services without it only know about stream keys
+func (g *GRPC) Authenticate(ctx context.Context, req Request) (*Result, error) {
+	if req.Action == ActionPlay && !g.playAuth {
+		return &Result{Allowed: true}, nil
+	}
+	if !g.breaker.allow() {
+		return g.fallback(req, errBreakerOpen)
+	}
+	response, err := g.get(ctx, req)
+	if err != nil {
+		if g.breaker.failure() {
+			g.log.Println("[ERROR] users info service keeps failing, stopped calling it: " + err.Error())
+		}
+		return g.fallback(req, err)
+	}
+	g.breaker.success()
+
+	result := Result{
+		Allowed: response.GetAuth(),
+		Record:  response.GetRecord(),
+		Backup:  response.GetBackup(),
+		Play:    response.GetPlay(),
+		Twitch:  response.GetTwitch().GetKey(),
+		Youtube: response.GetYoutube().GetKey(),
+		Aparat:  response.GetAparat().GetKey(),
+	}
+	if g.policy == "lastgood" {
+		g.remember(req, result)
+	}
+	return &result, nil
+}
+
+// get calls the service and retries transient failures with backoff
+func (g *GRPC) get(ctx context.Context, req Request) (*protos.UsersInfoResponse, error) {
+	backoff := g.backoff
+	for attempt := 0; ; attempt++ {
+		callCtx, cancel := context.WithTimeout(ctx, g.timeout)
+		response, err := g.RPC.Get(callCtx, &protos.UsersInfoRequest{
+			Key:    req.Key,
+			Action: req.Action,
+			App:    req.App,
+			Ip:     req.IP,
+			Backup: req.Backup,
+		})
+		cancel()
+		if err == nil || attempt >= g.retries || !transient(err) {
+			return response, err
+		}
+		g.log.Printf("[WARNING] users info call failed, retrying in %s: %s\n", backoff, err.Error())
+		select {
+		case <-time.After(backoff):
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+		backoff *= 2
+	}
+}
+
+// remember keeps the result of the key for LastGoodTTL
+// the expired results of the others are removed meanwhile
+func (g *GRPC) remember(req Request, result Result) {
+	now := time.Now()
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	for k, e := range g.lastGood {
+		if now.After(e.expires) {
+			delete(g.lastGood, k)
+		}
+	}
+	g.lastGood[req.Action+" "+req.Key] = cacheEntry{result: result, expires: now.Add(g.lastGoodTTL)}
+}
+
+// fallback answers with the last result of the key when the policy allows it
+func (g *GRPC) fallback(req Request, err error) (*Result, error) {
+	if g.policy != "lastgood" {
+		return nil, err
+	}
+	g.mu.Lock()
+	entry, ok := g.lastGood[req.Action+" "+req.Key]
+	g.mu.Unlock()
+	if !ok || time.Now().After(entry.expires) {
+		return nil, err
+	}
+	g.log.Println("[WARNING] using the last known result of a key: " + err.Error())
+	result := entry.result
+	return &result, nil
+}
+
+// transient reports whether the call may succeed if it's tried again
+func transient(err error) bool {
+	switch status.Code(err) {
+	case codes.Unavailable, codes.DeadlineExceeded, codes.ResourceExhausted, codes.Aborted:
+		return true
+	}
+	return false
+}
@@ -0,0 +1,12 @@
+package auth
+
+import "context"
+
+// None allows every client and sends the streams nowhere
+// it is meant for development only
+type None struct{}
+
+// Authenticate allows the request
+func (None) Authenticate(ctx context.Context, req Request) (*Result, error) {
+	return &Result{Allowed: true}, nil
+}
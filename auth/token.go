@@ -0,0 +1,164 @@
+package auth
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"log"
+	"net"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/alipourhabibi/restream/logging"
+)
+
+// Token authorizes stream names signed with a shared secret like
+// key?exp=1700000000&app=live&ip=10.0.0.0/8&nonce=abc&sig=...
+// exp is a unix time, app the only app the key can be used in, ip a comma
+// separated list of ips or ranges, nonce makes the name usable once
+// and action limits it to publish or play. all of them are optional
+// sig is the hex HMAC-SHA256 of the name without sig, see Sign
+// the name of a backup publisher is signed without the BackupSuffix
+//
+// a valid token is allowed even if Next fails so publishers and players
+// keep working while it is down. Next still gives the destinations and
+// its deny of a revoked key is kept
+type Token struct {
+	log      *log.Logger
+	secret   []byte
+	required bool
+	Next     Authenticator
+
+	mu     sync.Mutex
+	nonces map[string]time.Time
+}
+
+// NewToken returns a Token which checks the names before next
+// when required is true names without sig are rejected
+// otherwise they are passed to next as before
+func NewToken(log *log.Logger, secret string, required bool, next Authenticator) *Token {
+	return &Token{
+		log:      log,
+		secret:   []byte(secret),
+		required: required,
+		Next:     next,
+		nonces:   make(map[string]time.Time),
+	}
+}
+
+// Sign returns the stream name of key with params and their signature
+func Sign(secret, key string, params url.Values) string {
+	params.Del("sig")
+	query := params.Encode()
+	params.Set("sig", signature([]byte(secret), key, query))
+	return key + "?" + params.Encode()
+}
+
+func signature(secret []byte, key, query string) string {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte(key + "?" + query))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// Authenticate verifies the token and asks Next for the rest of the result
+func (t *Token) Authenticate(ctx context.Context, req Request) (*Result, error) {
+	params, err := url.ParseQuery(req.Query)
+	if err != nil || params.Get("sig") == "" {
+		if t.required {
+			return &Result{}, nil
+		}
+		return t.Next.Authenticate(ctx, req)
+	}
+	if err := t.verify(req, params); err != nil {
+		t.log.Printf("rejected token of %s from %s: %s\n", logging.Redact(req.Key), req.IP, err.Error())
+		return &Result{}, nil
+	}
+
+	result, err := t.Next.Authenticate(ctx, req)
+	if err != nil {
+		t.log.Println("[WARNING] allowing the token of " + logging.Redact(req.Key) + " without destinations: " + err.Error())
+		return &Result{Allowed: true}, nil
+	}
+	return result, nil
+}
+
+func (t *Token) verify(req Request, params url.Values) error {
+	sig := params.Get("sig")
+	params.Del("sig")
+	expected := signature(t.secret, req.Key, params.Encode())
+	if !hmac.Equal([]byte(sig), []byte(expected)) {
+		return errors.New("invalid signature")
+	}
+
+	var exp time.Time
+	if v := params.Get("exp"); v != "" {
+		unix, err := strconv.ParseInt(v, 10, 64)
+		if err != nil {
+			return errors.New("invalid exp")
+		}
+		exp = time.Unix(unix, 0)
+		if time.Now().After(exp) {
+			return errors.New("expired")
+		}
+	}
+	if v := params.Get("action"); v != "" && v != req.Action {
+		return errors.New("not allowed to " + req.Action)
+	}
+	if v := params.Get("app"); v != "" && v != req.App {
+		return errors.New("not allowed in app " + req.App)
+	}
+	if v := params.Get("ip"); v != "" && !ipAllowed(req.IP, v) {
+		return errors.New("not allowed from this ip")
+	}
+	if nonce := params.Get("nonce"); nonce != "" {
+		// without exp a used nonce would have to be kept forever
+		if exp.IsZero() {
+			return errors.New("nonce without exp")
+		}
+		if !t.useNonce(nonce, exp) {
+			return errors.New("nonce is already used")
+		}
+	}
+	return nil
+}
+
+// useNonce reports whether the nonce was unused and marks it used until exp
+func (t *Token) useNonce(nonce string, exp time.Time) bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	now := time.Now()
+	for n, e := range t.nonces {
+		if now.After(e) {
+			delete(t.nonces, n)
+		}
+	}
+	if _, ok := t.nonces[nonce]; ok {
+		return false
+	}
+	t.nonces[nonce] = exp
+	return true
+}
+
+// ipAllowed reports whether ip is one of the comma separated ips or ranges
+func ipAllowed(ip, ranges string) bool {
+	addr := net.ParseIP(ip)
+	if addr == nil {
+		return false
+	}
+	for _, r := range strings.Split(ranges, ",") {
+		r = strings.TrimSpace(r)
+		if _, network, err := net.ParseCIDR(r); err == nil {
+			if network.Contains(addr) {
+				return true
+			}
+		} else if other := net.ParseIP(r); other != nil && other.Equal(addr) {
+			return true
+		}
+	}
+	return false
+}
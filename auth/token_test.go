@@ -0,0 +1,132 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log"
+	"net/url"
+	"strconv"
+	"testing"
+	"time"
+)
+
+const testSecret = "secret"
+
+type stubAuth struct {
+	result *Result
+	err    error
+}
+
+func (s stubAuth) Authenticate(ctx context.Context, req Request) (*Result, error) {
+	return s.result, s.err
+}
+
+func newTestToken(required bool, next Authenticator) *Token {
+	return NewToken(log.New(io.Discard, "", 0), testSecret, required, next)
+}
+
+// signed returns the query of key signed with params
+func signed(t *testing.T, secret, key string, params url.Values) string {
+	t.Helper()
+	u, err := url.Parse(Sign(secret, key, params))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return u.RawQuery
+}
+
+func TestTokenVerify(t *testing.T) {
+	future := strconv.FormatInt(time.Now().Add(time.Hour).Unix(), 10)
+	past := strconv.FormatInt(time.Now().Add(-time.Hour).Unix(), 10)
+	publish := Request{Action: ActionPublish, App: "live", Key: "key", IP: "10.1.2.3"}
+
+	tests := []struct {
+		name   string
+		secret string
+		key    string
+		params url.Values
+		req    Request
+		err    string
+	}{
+		{"no params", testSecret, "key", url.Values{}, publish, ""},
+		{"every param", testSecret, "key", url.Values{
+			"exp": {future}, "action": {ActionPublish}, "app": {"live"}, "ip": {"192.168.0.1, 10.0.0.0/8"}, "nonce": {"every"},
+		}, publish, ""},
+		{"other secret", "other", "key", url.Values{}, publish, "invalid signature"},
+		{"other key", testSecret, "other", url.Values{}, publish, "invalid signature"},
+		{"invalid exp", testSecret, "key", url.Values{"exp": {"soon"}}, publish, "invalid exp"},
+		{"expired", testSecret, "key", url.Values{"exp": {past}}, publish, "expired"},
+		{"other action", testSecret, "key", url.Values{"action": {ActionPlay}}, publish, "not allowed to publish"},
+		{"other app", testSecret, "key", url.Values{"app": {"vod"}}, publish, "not allowed in app live"},
+		{"other ip", testSecret, "key", url.Values{"ip": {"10.1.2.4"}}, publish, "not allowed from this ip"},
+		{"other range", testSecret, "key", url.Values{"ip": {"192.168.0.0/16"}}, publish, "not allowed from this ip"},
+		{"nonce without exp", testSecret, "key", url.Values{"nonce": {"abc"}}, publish, "nonce without exp"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			params, err := url.ParseQuery(signed(t, tt.secret, tt.key, tt.params))
+			if err != nil {
+				t.Fatal(err)
+			}
+			err = newTestToken(true, nil).verify(tt.req, params)
+			if got := errString(err); got != tt.err {
+				t.Errorf("verify() = %q, want %q", got, tt.err)
+			}
+		})
+	}
+}
+
+func TestTokenNonce(t *testing.T) {
+	token := newTestToken(true, nil)
+	exp := strconv.FormatInt(time.Now().Add(time.Hour).Unix(), 10)
+	query := signed(t, testSecret, "key", url.Values{"exp": {exp}, "nonce": {"once"}})
+	req := Request{Action: ActionPublish, Key: "key"}
+
+	for i, want := range []string{"", "nonce is already used"} {
+		params, _ := url.ParseQuery(query)
+		if got := errString(token.verify(req, params)); got != want {
+			t.Errorf("use %d: verify() = %q, want %q", i+1, got, want)
+		}
+	}
+}
+
+func TestTokenAuthenticate(t *testing.T) {
+	valid := signed(t, testSecret, "key", url.Values{})
+	invalid := signed(t, "other", "key", url.Values{})
+	allowed := &Result{Allowed: true, Record: true}
+
+	tests := []struct {
+		name     string
+		required bool
+		query    string
+		next     stubAuth
+		want     Result
+	}{
+		{"valid token gets the result of next", true, valid, stubAuth{result: allowed}, *allowed},
+		{"valid token keeps the deny of next", true, valid, stubAuth{result: &Result{}}, Result{}},
+		{"valid token is allowed while next fails", true, valid, stubAuth{err: errors.New("down")}, Result{Allowed: true}},
+		{"invalid token is denied", false, invalid, stubAuth{result: allowed}, Result{}},
+		{"required token is missing", true, "", stubAuth{result: allowed}, Result{}},
+		{"optional token is missing", false, "", stubAuth{result: allowed}, *allowed},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := Request{Action: ActionPublish, Key: "key", Query: tt.query}
+			result, err := newTestToken(tt.required, tt.next).Authenticate(context.Background(), req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if result.Allowed != tt.want.Allowed || result.Record != tt.want.Record {
+				t.Errorf("Authenticate() = %+v, want %+v", *result, tt.want)
+			}
+		})
+	}
+}
+
+func errString(err error) string {
+	if err == nil {
+		return ""
+	}
+	return err.Error()
+}
@@ -0,0 +1,59 @@
+package auth
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+// Webhook authorizes by posting the request as json to a url
+// which answers with a json Result
+type Webhook struct {
+	URL    string
+	client *http.Client
+}
+
+// NewWebhook returns a Webhook which waits timeout milliseconds for the answer
+func NewWebhook(url string, timeout int) *Webhook {
+	if timeout <= 0 {
+		timeout = 5000
+	}
+	return &Webhook{
+		URL:    url,
+		client: &http.Client{Timeout: time.Duration(timeout) * time.Millisecond},
+	}
+}
+
+// Authenticate posts the request to the webhook
+// any status other than 200 rejects the client
+func (w *Webhook) Authenticate(ctx context.Context, req Request) (*Result, error) {
+	body, err := json.Marshal(req)
+	if err != nil {
+		return nil, err
+	}
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, w.URL, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	httpReq.Header.Set("Content-Type", "application/json")
+	resp, err := w.client.Do(httpReq)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusUnauthorized || resp.StatusCode == http.StatusForbidden {
+		return &Result{}, nil
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("auth webhook returned %s", resp.Status)
+	}
+	result := &Result{}
+	if err := json.NewDecoder(resp.Body).Decode(result); err != nil {
+		return nil, fmt.Errorf("auth webhook: %v", err)
+	}
+	return result, nil
+}
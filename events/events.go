@@ -0,0 +1,122 @@
+package events
+
+import (
+	"expvar"
+	"log"
+	"sync"
+	"time"
+)
+
+// Type is what happened to a stream
+type Type string
+
+// Types of the events
+const (
+	PublishStart            Type = "publish.start"
+	PublishStop             Type = "publish.stop"
+	PublishCodec            Type = "publish.codec"
+	Connect                 Type = "connect"
+	PlayStart               Type = "play.start"
+	RecordFinished          Type = "record.finished"
+	DestinationConnected    Type = "destination.connected"
+	DestinationFailed       Type = "destination.failed"
+	DestinationReconnecting Type = "destination.reconnecting"
+	DestinationStop         Type = "destination.stop"
+	DestinationViolation    Type = "destination.violation"
+)
+
+// Codec describes the media of a publisher, the profiles and the rest
+// are read from the sequence headers of h264, hevc and aac
+type Codec struct {
+	Video        string  `json:"video,omitempty"`
+	Width        int     `json:"width,omitempty"`
+	Height       int     `json:"height,omitempty"`
+	VideoProfile string  `json:"videoProfile,omitempty"`
+	VideoLevel   string  `json:"videoLevel,omitempty"`
+	FrameRate    float64 `json:"frameRate,omitempty"`
+	Chroma       string  `json:"chroma,omitempty"`
+	BitDepth     int     `json:"bitDepth,omitempty"`
+	Audio        string  `json:"audio,omitempty"`
+	SampleRate   int     `json:"sampleRate,omitempty"`
+	Channels     int     `json:"channels,omitempty"`
+	// audio object type of aac, 2 is lc and 5 is he
+	AudioObjectType int `json:"audioObjectType,omitempty"`
+}
+
+// Event is something that happened to a publish session or one of its destinations
+// Bytes and Duration in milliseconds are the totals when it stops
+// or of the file when a recording is finished
+type Event struct {
+	Type Type      `json:"type"`
+	Time time.Time `json:"time"`
+	App  string    `json:"app"`
+	// the stream key is the credential of the publisher so it's never encoded
+	Key         string `json:"-"`
+	Session     string `json:"session"`
+	Destination string `json:"destination,omitempty"`
+	IP          string `json:"ip,omitempty"`
+	File        string `json:"file,omitempty"`
+	Error       string `json:"error,omitempty"`
+	Bytes       int64  `json:"bytes,omitempty"`
+	Duration    int64  `json:"duration,omitempty"`
+	Codec       *Codec `json:"codec,omitempty"`
+}
+
+// metrics of the subscribers which are served in /debug/vars
+var metrics = expvar.NewMap("events")
+
+type subscriber struct {
+	name   string
+	events chan Event
+}
+
+// Dispatcher passes the events to every subscriber in the background
+// so a slow subscriber never blocks the streams
+type Dispatcher struct {
+	log         *log.Logger
+	mu          sync.Mutex
+	subscribers []*subscriber
+}
+
+// NewDispatcher returns a Dispatcher without subscribers
+func NewDispatcher(log *log.Logger) *Dispatcher {
+	return &Dispatcher{log: log}
+}
+
+// Subscribe calls handle for every published event in its own goroutine
+// events are dropped when more than buffer of them are waiting
+func (d *Dispatcher) Subscribe(name string, buffer int, handle func(Event)) {
+	if buffer <= 0 {
+		buffer = 256
+	}
+	s := &subscriber{name: name, events: make(chan Event, buffer)}
+	metrics.Add("subscribers", 1)
+	d.mu.Lock()
+	d.subscribers = append(d.subscribers, s)
+	d.mu.Unlock()
+	go func() {
+		for e := range s.events {
+			handle(e)
+		}
+	}()
+}
+
+// Publish sends the event to the subscribers, it can be called on a nil Dispatcher
+func (d *Dispatcher) Publish(e Event) {
+	if d == nil {
+		return
+	}
+	if e.Time.IsZero() {
+		e.Time = time.Now()
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	for _, s := range d.subscribers {
+		select {
+		case s.events <- e:
+		default:
+			metrics.Add(s.name+".dropped", 1)
+			d.log.Printf("[WARNING] dropped %s event for %s, it is too slow\n", e.Type, s.name)
+		}
+	}
+}
@@ -0,0 +1,66 @@
+package events
+
+import (
+	"context"
+	"log"
+	"time"
+
+	protos "github.com/alipourhabibi/restream/protos/usersinfo"
+)
+
+// Reporter sends the events to the Report rpc of the UsersInfo service
+type Reporter struct {
+	log     *log.Logger
+	RPC     protos.UsersInfoClient
+	timeout time.Duration
+}
+
+// NewReporter returns a Reporter which waits timeout for each call
+func NewReporter(log *log.Logger, client protos.UsersInfoClient, timeout time.Duration) *Reporter {
+	if timeout <= 0 {
+		timeout = 3 * time.Second
+	}
+	return &Reporter{log: log, RPC: client, timeout: timeout}
+}
+
+// Handle reports the event, failed reports are logged and dropped
+func (r *Reporter) Handle(e Event) {
+	ctx, cancel := context.WithTimeout(context.Background(), r.timeout)
+	defer cancel()
+	if _, err := r.RPC.Report(ctx, toProto(e)); err != nil {
+		r.log.Printf("[ERROR] reporting %s event: %s\n", e.Type, err.Error())
+	}
+}
+
+func toProto(e Event) *protos.StreamEvent {
+	event := &protos.StreamEvent{
+		Type:        string(e.Type),
+		Time:        e.Time.UnixMilli(),
+		App:         e.App,
+		Key:         e.Key,
+		Session:     e.Session,
+		Destination: e.Destination,
+		Error:       e.Error,
+		Bytes:       e.Bytes,
+		Duration:    e.Duration,
+		Ip:          e.IP,
+		File:        e.File,
+	}
+	if e.Codec != nil {
+		event.Codec = &protos.Codec{
+			Video:           e.Codec.Video,
+			Width:           int32(e.Codec.Width),
+			Height:          int32(e.Codec.Height),
+			Audio:           e.Codec.Audio,
+			SampleRate:      int32(e.Codec.SampleRate),
+			Channels:        int32(e.Codec.Channels),
+			VideoProfile:    e.Codec.VideoProfile,
+			VideoLevel:      e.Codec.VideoLevel,
+			FrameRate:       e.Codec.FrameRate,
+			Chroma:          e.Codec.Chroma,
+			BitDepth:        int32(e.Codec.BitDepth),
+			AudioObjectType: int32(e.Codec.AudioObjectType),
+		}
+	}
+	return event
+}
@@ -0,0 +1,134 @@
+package events
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/alipourhabibi/restream/logging"
+	"github.com/alipourhabibi/restream/record"
+)
+
+// Webhook posts the events as json to a url
+// the body is signed with Secret in the X-Restream-Signature header
+// and failed deliveries are retried with backoff
+type Webhook struct {
+	log     *log.Logger
+	URL     string
+	Secret  string
+	types   map[Type]bool
+	retries int
+	backoff time.Duration
+	client  *http.Client
+}
+
+// NewWebhook returns a Webhook which posts the given types of events to url
+// or every event when types is empty, it waits timeout for each post
+func NewWebhook(log *log.Logger, url, secret string, types []Type, timeout time.Duration, retries int, backoff time.Duration) *Webhook {
+	if timeout <= 0 {
+		timeout = 5 * time.Second
+	}
+	if backoff <= 0 {
+		backoff = time.Second
+	}
+	w := &Webhook{
+		log:     log,
+		URL:     url,
+		Secret:  secret,
+		retries: retries,
+		backoff: backoff,
+		client:  &http.Client{Timeout: timeout},
+	}
+	if len(types) > 0 {
+		w.types = make(map[Type]bool)
+		for _, t := range types {
+			w.types[t] = true
+		}
+	}
+	return w
+}
+
+// redacted is an event for the webhooks which are often third parties
+// its key is the one in the logs and it's removed from the file name
+type redacted struct {
+	Event
+	Key string `json:"key"`
+}
+
+func redact(e Event) redacted {
+	key := logging.Redact(e.Key)
+	if e.Key != "" {
+		// the key is in the recorded file names without its path separators
+		e.File = strings.ReplaceAll(e.File, record.Sanitize(e.Key), key)
+	}
+	return redacted{Event: e, Key: key}
+}
+
+// Handle delivers the event, it gives up after the retries and logs it
+func (w *Webhook) Handle(e Event) {
+	if w.types != nil && !w.types[e.Type] {
+		return
+	}
+	body, err := json.Marshal(redact(e))
+	if err != nil {
+		w.log.Printf("[ERROR] encoding %s event: %s\n", e.Type, err.Error())
+		return
+	}
+
+	backoff := w.backoff
+	for attempt := 0; ; attempt++ {
+		retry, err := w.post(e.Type, body)
+		if err == nil {
+			metrics.Add("webhook.delivered", 1)
+			return
+		}
+		if !retry || attempt >= w.retries {
+			metrics.Add("webhook.failed", 1)
+			w.log.Printf("[ERROR] webhook %s event: %s\n", e.Type, err.Error())
+			return
+		}
+		metrics.Add("webhook.retries", 1)
+		w.log.Printf("[WARNING] webhook %s event failed, retrying in %s: %s\n", e.Type, backoff, err.Error())
+		time.Sleep(backoff)
+		backoff *= 2
+	}
+}
+
+// post sends the body once and reports whether a failure is worth retrying
+func (w *Webhook) post(t Type, body []byte) (bool, error) {
+	req, err := http.NewRequest(http.MethodPost, w.URL, bytes.NewReader(body))
+	if err != nil {
+		return false, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Restream-Event", string(t))
+	if w.Secret != "" {
+		req.Header.Set("X-Restream-Signature", "sha256="+Sign(w.Secret, body))
+	}
+	resp, err := w.client.Do(req)
+	if err != nil {
+		return true, err
+	}
+	resp.Body.Close()
+
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		return false, nil
+	}
+	retry := resp.StatusCode >= 500 || resp.StatusCode == http.StatusTooManyRequests
+	return retry, fmt.Errorf("%s returned %s", w.URL, resp.Status)
+}
+
+// Sign returns the hex HMAC-SHA256 of body which receivers compare
+// with the X-Restream-Signature header
+func Sign(secret string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
@@ -1,14 +1,21 @@
 package grpcclient
 
 import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
 	"fmt"
 	"log"
+	"net"
+	"os"
 
 	protos "github.com/alipourhabibi/restream/protos/usersinfo"
 	"github.com/alipourhabibi/restream/settings"
 
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/health/grpc_health_v1"
 )
 
 type Keys struct {
@@ -19,7 +26,8 @@ type Keys struct {
 
 // A UsersInfo struct Consists of a logger field and a SetUp method
 type UsersInfo struct {
-	log *log.Logger
+	log  *log.Logger
+	conn *grpc.ClientConn
 }
 
 // NewUsersInfo returns a new instance of &UsersInfo with given logger
@@ -28,19 +36,75 @@ func NewUsersInfo(log *log.Logger) *UsersInfo {
 }
 
 // Get method is used to get user's data including keys from grpc server
-func (u *UsersInfo) GetClient() protos.UsersInfoClient {
-	creds, err := credentials.NewClientTLSFromFile("certfiles/grpc/cert.pem", "")
+func (u *UsersInfo) GetClient() (protos.UsersInfoClient, error) {
+	creds, err := transportCredentials()
 	if err != nil {
-		u.log.Println(err.Error())
+		return nil, err
 	}
-	dial := fmt.Sprintf("%s:%d", settings.GRPCUsersInfoSettings.Items.Host, settings.GRPCUsersInfoSettings.Items.Port)
+	dial := fmt.Sprintf("%s:%d", settings.GRPCUsersInfoSettings().Items.Host, settings.GRPCUsersInfoSettings().Items.Port)
 	con, err := grpc.Dial(dial, grpc.WithTransportCredentials(creds))
 	if err != nil {
-		u.log.Println(err.Error())
+		return nil, err
 	}
+	u.conn = con
 
 	// UsersInfo Client
 	client := protos.NewUsersInfoClient(con)
 
-	return client
+	return client, nil
+}
+
+// transportCredentials builds the tls config of the grpcusersinfo section
+// with our own certificate when the service verifies its clients
+func transportCredentials() (credentials.TransportCredentials, error) {
+	items := settings.GRPCUsersInfoSettings().Items
+	if items.Insecure {
+		if !isLoopback(items.Host) {
+			return nil, fmt.Errorf("grpcusersinfo Insecure is only allowed for localhost, not %s", items.Host)
+		}
+		return insecure.NewCredentials(), nil
+	}
+
+	config := &tls.Config{ServerName: items.ServerName}
+	if items.CAFile != "" {
+		pem, err := os.ReadFile(items.CAFile)
+		if err != nil {
+			return nil, err
+		}
+		config.RootCAs = x509.NewCertPool()
+		if !config.RootCAs.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no certificates found in %s", items.CAFile)
+		}
+	}
+	if items.CertFile != "" || items.KeyFile != "" {
+		if items.CertFile == "" || items.KeyFile == "" {
+			return nil, fmt.Errorf("grpcusersinfo needs both CertFile and KeyFile for client certificates")
+		}
+		cert, err := tls.LoadX509KeyPair(items.CertFile, items.KeyFile)
+		if err != nil {
+			return nil, err
+		}
+		config.Certificates = []tls.Certificate{cert}
+	}
+	return credentials.NewTLS(config), nil
+}
+
+func isLoopback(host string) bool {
+	if host == "localhost" {
+		return true
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
+
+// CheckHealth asks the service whether it is serving with the grpc health protocol
+func (u *UsersInfo) CheckHealth(ctx context.Context) error {
+	response, err := grpc_health_v1.NewHealthClient(u.conn).Check(ctx, &grpc_health_v1.HealthCheckRequest{})
+	if err != nil {
+		return err
+	}
+	if response.GetStatus() != grpc_health_v1.HealthCheckResponse_SERVING {
+		return fmt.Errorf("users info service is %s", response.GetStatus())
+	}
+	return nil
 }
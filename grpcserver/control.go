@@ -0,0 +1,126 @@
+package grpcserver
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"os"
+
+	protos "github.com/alipourhabibi/restream/protos/control"
+	"github.com/alipourhabibi/restream/rtmp"
+	"github.com/alipourhabibi/restream/settings"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/status"
+)
+
+// Control is the grpc server which lets our backend act on the live streams
+type Control struct {
+	protos.UnimplementedControlServer
+	log    *log.Logger
+	stream *rtmp.Stream
+}
+
+// NewControl returns a Control for the streams of stream
+func NewControl(log *log.Logger, stream *rtmp.Stream) *Control {
+	return &Control{log: log, stream: stream}
+}
+
+// ListenAndServe serves the control rpcs with tls on the host and port of the control section
+// only to the clients with a certificate signed by ClientCAFile
+func (s *Control) ListenAndServe() error {
+	items := settings.ControlSettings().Items
+	cert, err := tls.LoadX509KeyPair(items.CertFile, items.KeyFile)
+	if err != nil {
+		return err
+	}
+	pem, err := os.ReadFile(items.ClientCAFile)
+	if err != nil {
+		return err
+	}
+	clientCAs := x509.NewCertPool()
+	if !clientCAs.AppendCertsFromPEM(pem) {
+		return fmt.Errorf("no certificates found in %s", items.ClientCAFile)
+	}
+	creds := credentials.NewTLS(&tls.Config{
+		Certificates: []tls.Certificate{cert},
+		ClientAuth:   tls.RequireAndVerifyClientCert,
+		ClientCAs:    clientCAs,
+	})
+	addr := fmt.Sprintf("%s:%d", items.Host, items.Port)
+	ln, err := net.Listen("tcp", addr)
+	if err != nil {
+		return err
+	}
+	server := grpc.NewServer(grpc.Creds(creds))
+	protos.RegisterControlServer(server, s)
+	s.log.Println("control grpc listening on", addr)
+	return server.Serve(ln)
+}
+
+// ListStreams returns the live streams
+func (s *Control) ListStreams(ctx context.Context, req *protos.StreamsRequest) (*protos.StreamsResponse, error) {
+	response := &protos.StreamsResponse{}
+	for _, info := range s.stream.Streams() {
+		response.Streams = append(response.Streams, &protos.Stream{
+			Key:          info.Key,
+			App:          info.App,
+			Session:      info.Session,
+			Play:         info.Play,
+			Started:      info.Started.UnixMilli(),
+			Destinations: info.Destinations,
+			Players:      int32(info.Players),
+		})
+	}
+	return response, nil
+}
+
+// Kick disconnects the publishers of the key
+func (s *Control) Kick(ctx context.Context, req *protos.KickRequest) (*protos.ControlResponse, error) {
+	if req.GetKey() == "" {
+		return nil, status.Error(codes.InvalidArgument, "key is required")
+	}
+	return &protos.ControlResponse{}, toStatus(s.stream.Kick(req.GetKey(), req.GetReason()))
+}
+
+// AddDestination starts sending the stream to a new destination
+func (s *Control) AddDestination(ctx context.Context, req *protos.DestinationRequest) (*protos.ControlResponse, error) {
+	if req.GetKey() == "" || req.GetName() == "" || req.GetUrl() == "" {
+		return nil, status.Error(codes.InvalidArgument, "key, name and url are required")
+	}
+	return &protos.ControlResponse{}, toStatus(s.stream.AddDestination(req.GetKey(), req.GetName(), req.GetUrl()))
+}
+
+// RemoveDestination stops sending the stream to a destination
+func (s *Control) RemoveDestination(ctx context.Context, req *protos.DestinationRequest) (*protos.ControlResponse, error) {
+	if req.GetKey() == "" || req.GetName() == "" {
+		return nil, status.Error(codes.InvalidArgument, "key and name are required")
+	}
+	return &protos.ControlResponse{}, toStatus(s.stream.RemoveDestination(req.GetKey(), req.GetName()))
+}
+
+// UpdateDestination reconnects a destination with a new url
+func (s *Control) UpdateDestination(ctx context.Context, req *protos.DestinationRequest) (*protos.ControlResponse, error) {
+	if req.GetKey() == "" || req.GetName() == "" || req.GetUrl() == "" {
+		return nil, status.Error(codes.InvalidArgument, "key, name and url are required")
+	}
+	return &protos.ControlResponse{}, toStatus(s.stream.UpdateDestination(req.GetKey(), req.GetName(), req.GetUrl()))
+}
+
+func toStatus(err error) error {
+	switch {
+	case err == nil:
+		return nil
+	case errors.Is(err, rtmp.ErrStreamNotFound), errors.Is(err, rtmp.ErrDestinationNotFound):
+		return status.Error(codes.NotFound, err.Error())
+	case errors.Is(err, rtmp.ErrDestinationExists):
+		return status.Error(codes.AlreadyExists, err.Error())
+	}
+	return status.Error(codes.Internal, err.Error())
+}
@@ -0,0 +1,147 @@
+package logging
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// rotatedLayout is added to the path of the rotated files
+const rotatedLayout = "20060102-150405.000"
+
+// File is a log file which is rotated when it gets larger than maxSize
+// or when a new interval starts, only the last keep rotated files are kept
+type File struct {
+	mu       sync.Mutex
+	path     string
+	maxSize  int64
+	interval time.Duration
+	keep     int
+	file     *os.File
+	size     int64
+	opened   time.Time
+}
+
+// OpenFile opens or creates the log file at path, zero maxSize,
+// interval or keep disable the rotation by size, by time or the removal
+func OpenFile(path string, maxSize int64, interval time.Duration, keep int) (*File, error) {
+	f := &File{path: path, maxSize: maxSize, interval: interval, keep: keep}
+	file, err := f.open()
+	if err != nil {
+		return nil, err
+	}
+	f.file = file
+	return f, nil
+}
+
+// open opens the file at path and sets its size and age
+func (f *File) open() (*os.File, error) {
+	file, err := os.OpenFile(f.path, os.O_APPEND|os.O_WRONLY|os.O_CREATE, 0666)
+	if err != nil {
+		return nil, err
+	}
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return nil, err
+	}
+	f.size = info.Size()
+	// a file left from before a restart is as old as its last line
+	f.opened = time.Now()
+	if f.size > 0 {
+		f.opened = info.ModTime()
+	}
+	return file, nil
+}
+
+func (f *File) Write(p []byte) (int, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.due(len(p)) {
+		if err := f.rotate(); err != nil {
+			// there is no log for the errors of the log, the lines are
+			// written to the old file until the next try
+			fmt.Fprintln(os.Stderr, "rotating", f.path, "failed:", err.Error())
+		}
+	}
+	n, err := f.file.Write(p)
+	f.size += int64(n)
+	return n, err
+}
+
+// due reports whether the file is rotated before writing n bytes
+func (f *File) due(n int) bool {
+	if f.size == 0 {
+		return false
+	}
+	if f.maxSize > 0 && f.size+int64(n) > f.maxSize {
+		return true
+	}
+	return f.interval > 0 && !time.Now().Truncate(f.interval).Equal(f.opened.Truncate(f.interval))
+}
+
+// rotate renames the file with the current time and opens a new one
+func (f *File) rotate() error {
+	rotated := f.path + "." + time.Now().UTC().Format(rotatedLayout)
+	if err := os.Rename(f.path, rotated); err != nil {
+		return err
+	}
+	file, err := f.open()
+	if err != nil {
+		return err
+	}
+	f.file.Close()
+	f.file = file
+	return f.prune()
+}
+
+// prune removes the oldest rotated files when there are more than keep
+func (f *File) prune() error {
+	if f.keep <= 0 {
+		return nil
+	}
+	matches, err := filepath.Glob(f.path + ".*")
+	if err != nil {
+		return err
+	}
+	var rotated []string
+	for _, m := range matches {
+		if _, err := time.Parse(rotatedLayout, strings.TrimPrefix(m, f.path+".")); err == nil {
+			rotated = append(rotated, m)
+		}
+	}
+	// the layout sorts by time
+	sort.Strings(rotated)
+	for len(rotated) > f.keep {
+		if err := os.Remove(rotated[0]); err != nil {
+			return err
+		}
+		rotated = rotated[1:]
+	}
+	return nil
+}
+
+// Reopen opens the path again, it's used after the file is
+// moved by another program like logrotate
+func (f *File) Reopen() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	file, err := f.open()
+	if err != nil {
+		return err
+	}
+	f.file.Close()
+	f.file = file
+	return nil
+}
+
+// Close closes the file
+func (f *File) Close() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.file.Close()
+}
@@ -0,0 +1,300 @@
+// Package logging writes the lines of *log.Logger as leveled json or logfmt
+// the level of a line is its [DEBUG], [INFO], [WARNING] or [ERROR] prefix
+// and every subsystem has its own minimum level
+package logging
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Level is the severity of a line
+type Level int
+
+const (
+	Debug Level = iota
+	Info
+	Warning
+	Error
+)
+
+var levelNames = []string{"debug", "info", "warning", "error"}
+
+func (l Level) String() string {
+	return levelNames[l]
+}
+
+// ParseLevel returns the level of its name, like warning
+func ParseLevel(name string) (Level, error) {
+	for i, n := range levelNames {
+		if strings.EqualFold(name, n) {
+			return Level(i), nil
+		}
+	}
+	return Info, fmt.Errorf("unknown log level %q", name)
+}
+
+// Subsystems which have their own level
+const (
+	Main      = "main"
+	Handshake = "handshake"
+	Chunk     = "chunk"
+	AMF       = "amf"
+	Restream  = "restream"
+	GRPC      = "grpc"
+)
+
+// Logger writes the lines of the loggers it returns to its output
+type Logger struct {
+	mu     sync.Mutex
+	out    io.Writer
+	json   bool
+	level  Level
+	levels map[string]Level
+}
+
+// New returns a Logger writing logfmt or json lines to out
+func New(out io.Writer, format string) *Logger {
+	return &Logger{
+		out:    out,
+		json:   format == "json",
+		level:  Info,
+		levels: make(map[string]Level),
+	}
+}
+
+// SetOutput replaces the writer of the lines
+func (l *Logger) SetOutput(out io.Writer) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.out = out
+}
+
+// SetFormat switches between logfmt and json
+func (l *Logger) SetFormat(format string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.json = format == "json"
+}
+
+// SetLevel sets the minimum level of a subsystem
+// the subsystems without their own level use the one of Main
+func (l *Logger) SetLevel(subsystem string, level Level) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if subsystem == Main {
+		l.level = level
+		return
+	}
+	l.levels[subsystem] = level
+}
+
+// ResetLevels removes the levels of the subsystems so they use the one of Main
+func (l *Logger) ResetLevels() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.levels = make(map[string]Level)
+}
+
+// For returns a *log.Logger of a subsystem whose lines have the fields
+// fields can be nil and are read for every line, so they can change later
+func (l *Logger) For(subsystem string, fields *Fields) *log.Logger {
+	return log.New(&writer{logger: l, subsystem: subsystem, fields: fields}, "", 0)
+}
+
+// enabled reports whether lines of level are written for subsystem
+func (l *Logger) enabled(subsystem string, level Level) bool {
+	min, ok := l.levels[subsystem]
+	if !ok {
+		min = l.level
+	}
+	return level >= min
+}
+
+// Fields are the context of a connection, like its id and app
+type Fields struct {
+	mu     sync.RWMutex
+	keys   []string
+	values []string
+}
+
+// NewFields returns the fields of key value pairs
+func NewFields(pairs ...string) *Fields {
+	f := &Fields{}
+	for i := 0; i+1 < len(pairs); i += 2 {
+		f.Set(pairs[i], pairs[i+1])
+	}
+	return f
+}
+
+// Set adds a field or replaces its value
+func (f *Fields) Set(key, value string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for i, k := range f.keys {
+		if k == key {
+			f.values[i] = value
+			return
+		}
+	}
+	f.keys = append(f.keys, key)
+	f.values = append(f.values, value)
+}
+
+// append adds the fields which have a value to keys and values
+func (f *Fields) append(keys, values []string) ([]string, []string) {
+	if f == nil {
+		return keys, values
+	}
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+	for i, k := range f.keys {
+		if f.values[i] != "" {
+			keys = append(keys, k)
+			values = append(values, f.values[i])
+		}
+	}
+	return keys, values
+}
+
+// Redact returns a short hash of a stream key which can be logged
+// it's not the play name, so it can't be used to play the stream
+func Redact(key string) string {
+	if key == "" {
+		return ""
+	}
+	sum := sha256.Sum256([]byte("log:" + key))
+	return hex.EncodeToString(sum[:6])
+}
+
+// RedactURL replaces the stream name of an rtmp url, its last
+// part with the query, with the hash of Redact
+func RedactURL(url string) string {
+	i := strings.LastIndex(url, "/")
+	if i < 0 || strings.HasSuffix(url[:i], "/") {
+		return url
+	}
+	key := url[i+1:]
+	if j := strings.Index(key, "?"); j >= 0 {
+		key = key[:j]
+	}
+	return url[:i+1] + Redact(key)
+}
+
+// writer formats the lines of a *log.Logger
+type writer struct {
+	logger    *Logger
+	subsystem string
+	fields    *Fields
+}
+
+var prefixes = []struct {
+	prefix string
+	level  Level
+}{
+	{"[DEBUG]", Debug},
+	{"[INFO]", Info},
+	{"[WARNING]", Warning},
+	{"[ERROR]", Error},
+}
+
+func (w *writer) Write(p []byte) (int, error) {
+	msg := strings.TrimRight(string(p), "\n")
+	level := Info
+	for _, pr := range prefixes {
+		if strings.HasPrefix(msg, pr.prefix) {
+			level = pr.level
+			msg = strings.TrimSpace(msg[len(pr.prefix):])
+			break
+		}
+	}
+
+	l := w.logger
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if !l.enabled(w.subsystem, level) {
+		return len(p), nil
+	}
+
+	keys := []string{"time", "level", "subsystem"}
+	values := []string{now(), level.String(), w.subsystem}
+	keys, values = w.fields.append(keys, values)
+	if err := l.write(append(keys, "msg"), append(values, msg)); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+// Access writes a line without a level of the fields and
+// the key value pairs, it's used for the access log
+func (l *Logger) Access(fields *Fields, pairs ...string) error {
+	keys, values := fields.append([]string{"time"}, []string{now()})
+	for i := 0; i+1 < len(pairs); i += 2 {
+		keys = append(keys, pairs[i])
+		values = append(values, pairs[i+1])
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.write(keys, values)
+}
+
+// write writes a line in the format of l, l.mu is held
+func (l *Logger) write(keys, values []string) error {
+	var line []byte
+	if l.json {
+		line = jsonLine(keys, values)
+	} else {
+		line = logfmtLine(keys, values)
+	}
+	_, err := l.out.Write(line)
+	return err
+}
+
+func now() string {
+	return time.Now().UTC().Format("2006-01-02T15:04:05.000Z")
+}
+
+// jsonLine writes the fields as an object in their order
+func jsonLine(keys, values []string) []byte {
+	var b strings.Builder
+	b.WriteByte('{')
+	for i, k := range keys {
+		if i > 0 {
+			b.WriteByte(',')
+		}
+		key, _ := json.Marshal(k)
+		value, _ := json.Marshal(values[i])
+		b.Write(key)
+		b.WriteByte(':')
+		b.Write(value)
+	}
+	b.WriteString("}\n")
+	return []byte(b.String())
+}
+
+// logfmtLine writes the fields as key=value, values with spaces,
+// quotes or = are quoted
+func logfmtLine(keys, values []string) []byte {
+	var b strings.Builder
+	for i, k := range keys {
+		if i > 0 {
+			b.WriteByte(' ')
+		}
+		b.WriteString(k)
+		b.WriteByte('=')
+		v := values[i]
+		if v == "" || strings.ContainsAny(v, " =\"\\\t\r\n") {
+			v = fmt.Sprintf("%q", v)
+		}
+		b.WriteString(v)
+	}
+	b.WriteByte('\n')
+	return []byte(b.String())
+}
@@ -1,39 +1,374 @@
 package main
 
 import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"flag"
+	"fmt"
 	"log"
+	"net/url"
 	"os"
 	"os/signal"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
 
+	"github.com/alipourhabibi/restream/admin"
+	"github.com/alipourhabibi/restream/auth"
+	"github.com/alipourhabibi/restream/events"
+	"github.com/alipourhabibi/restream/grpcclient"
+	"github.com/alipourhabibi/restream/grpcserver"
+	"github.com/alipourhabibi/restream/logging"
+	"github.com/alipourhabibi/restream/record"
 	"github.com/alipourhabibi/restream/rtmp"
 	"github.com/alipourhabibi/restream/settings"
+	"github.com/alipourhabibi/restream/source"
 )
 
 func main() {
-	settings.SetUp()
+	flags := flag.NewFlagSet("restream", flag.ExitOnError)
+	configPath := flags.String("config", settings.DefaultPath, "config file, its keys are overridden by RESTREAM_SECTION_KEY environment variables")
+	flags.Parse(os.Args[1:])
+	args := flags.Args()
 
-	// Setting Up logger
-	logfile, err := os.OpenFile("general.log", os.O_APPEND|os.O_WRONLY|os.O_CREATE, 0666)
-	if err != nil {
-		panic(err)
+	if len(args) > 0 && args[0] == "config" {
+		os.Exit(config(*configPath, args[1:]))
+	}
+	if err := settings.SetUp(*configPath); err != nil {
+		fmt.Fprintln(os.Stderr, "invalid config:")
+		fmt.Fprintln(os.Stderr, err.Error())
+		os.Exit(1)
+	}
+
+	if len(args) > 0 && args[0] == "publish" {
+		os.Exit(publish(args[1:]))
+	}
+	if len(args) > 0 && args[0] == "token" {
+		os.Exit(token(args[1:]))
 	}
-	defer logfile.Close()
 
-	l := log.Logger{}
-	l.SetFlags(log.Ltime | log.Ldate | log.LUTC)
-	if settings.ServerSettings.Items.RunMode == "Release" {
-		l.SetOutput(logfile)
+	// Setting Up logger
+	items := settings.LogSettings().Items
+	logs := logging.New(os.Stderr, items.Format)
+	l := logs.For(logging.Main, nil)
+	// reopened on SIGUSR1
+	var files []*logging.File
+	var access *logging.Logger
+	// exits with it after the log files are closed
+	code := 0
+	defer func() {
+		if code != 0 {
+			os.Exit(code)
+		}
+	}()
+	if items.AccessFile != "" {
+		accessFile, err := openLog(items.AccessFile)
+		if err != nil {
+			panic(err)
+		}
+		defer accessFile.Close()
+		files = append(files, accessFile)
+		access = logging.New(accessFile, items.Format)
+	}
+	configureLogs(logs, access)
+	if settings.ServerSettings().Items.RunMode == "Release" {
+		logfile, err := openLog(items.File)
+		if err != nil {
+			panic(err)
+		}
+		defer logfile.Close()
+		files = append(files, logfile)
+		logs.SetOutput(logfile)
 	} else {
 		// Debug
-		l.SetOutput(os.Stderr)
+		l.Println("config", *configPath)
+		for _, line := range settings.Dump() {
+			l.Println(line)
+		}
 	}
 
-	stream := rtmp.NewStream(&l)
-	stream.InitStream()
+	authenticator, err := auth.New(logs)
+	if err != nil {
+		l.Fatalln(err.Error())
+	}
+	dispatcher := events.NewDispatcher(l)
+	if settings.EventsSettings().Items.Report {
+		client, err := grpcclient.NewUsersInfo(logs.For(logging.GRPC, nil)).GetClient()
+		if err != nil {
+			l.Fatalln(err.Error())
+		}
+		timeout := time.Duration(settings.GRPCUsersInfoSettings().Items.Timeout) * time.Millisecond
+		reporter := events.NewReporter(logs.For(logging.GRPC, nil), client, timeout)
+		dispatcher.Subscribe("users info", settings.EventsSettings().Items.Buffer, reporter.Handle)
+	}
+	subscribeWebhooks(l, dispatcher)
+	services, err := rtmp.LoadServices(settings.ServerSettings().Items.Services)
+	if err != nil {
+		l.Fatalln(err.Error())
+	}
+	stream := rtmp.NewStream(logs, authenticator, dispatcher, services)
+	if access != nil {
+		stream.SetAccessLog(access)
+	}
+	failed := make(chan error, 1)
+	go func() {
+		failed <- stream.InitStream()
+	}()
+	reload := reloader(logs, access, *configPath, stream)
+
+	if settings.ControlSettings().Items.Enabled {
+		go func() {
+			if err := grpcserver.NewControl(logs.For(logging.GRPC, nil), stream).ListenAndServe(); err != nil {
+				l.Println(err.Error())
+			}
+		}()
+	}
+
+	if settings.AdminSettings().Items.Enabled {
+		go func() {
+			adminServer := admin.NewServer(l, stream)
+			adminServer.Reload = reload
+			if err := adminServer.ListenAndServe(); err != nil {
+				l.Println(err.Error())
+			}
+		}()
+	}
 
 	c := make(chan os.Signal, 1)
-	signal.Notify(c, os.Interrupt)
+	signal.Notify(c, os.Interrupt, syscall.SIGTERM, syscall.SIGHUP, syscall.SIGUSR1)
+
+	for running := true; running; {
+		select {
+		case err := <-failed:
+			// the others are shut down like on a signal
+			l.Println("[ERROR] " + err.Error())
+			code = 1
+			running = false
+		case sig := <-c:
+			switch sig {
+			case syscall.SIGUSR1:
+				reopen(l, files)
+			case syscall.SIGHUP:
+				reload()
+			default:
+				running = false
+			}
+		}
+	}
+
+	l.Println("shutting down, send the signal again to exit right away")
+	go func() {
+		for sig := range c {
+			if sig != syscall.SIGHUP && sig != syscall.SIGUSR1 {
+				l.Println("[WARNING] exiting without draining")
+				os.Exit(1)
+			}
+		}
+	}()
+	drain := time.Duration(settings.ServerSettings().Items.DrainTimeout) * time.Second
+	ctx, cancel := context.WithTimeout(context.Background(), drain)
+	defer cancel()
+	if err := stream.Shutdown(ctx); err != nil {
+		l.Println("[WARNING] closed the connections which didn't drain in time")
+	}
+	// the last mp4 files are still being rewritten
+	record.Wait()
+	l.Println("shut down")
+}
+
+// reloader returns a func which reloads the config file and the services catalog
+// the authenticator is rebuilt when its settings or its keys file may have changed
+// an invalid config or catalog is logged and the old ones are kept
+func reloader(logs, access *logging.Logger, path string, stream *rtmp.Stream) func() ([]settings.Change, error) {
+	l := logs.For(logging.Main, nil)
+	return func() ([]settings.Change, error) {
+		changes, err := settings.Reload(path, func(changes []settings.Change) error {
+			services, err := rtmp.LoadServices(settings.ServerSettings().Items.Services)
+			if err != nil {
+				return err
+			}
+			rebuild := settings.AuthSettings().Items.Backend == "file"
+			for _, change := range changes {
+				switch change.Section {
+				case "auth", "token", "grpcusersinfo":
+					rebuild = true
+				}
+			}
+			var authenticator auth.Authenticator
+			if rebuild {
+				if authenticator, err = auth.New(logs); err != nil {
+					return err
+				}
+			}
+			stream.SetServices(services)
+			if authenticator != nil {
+				stream.SetAuth(authenticator)
+			}
+			configureLogs(logs, access)
+			return nil
+		})
+		if err != nil {
+			l.Println("[ERROR] reloading the config failed, the old one is kept:")
+			l.Println(err.Error())
+			return nil, err
+		}
+		for _, change := range changes {
+			if change.Restart {
+				l.Printf("[WARNING] %s.%s changed from %q to %q, it's used after a restart\n", change.Section, change.Key, change.Old, change.New)
+			} else {
+				l.Printf("%s.%s changed from %q to %q\n", change.Section, change.Key, change.Old, change.New)
+			}
+		}
+		l.Printf("reloaded %s with %d changes\n", path, len(changes))
+		return changes, nil
+	}
+}
 
-	<-c
-	os.Exit(0)
+// openLog opens a log file which is rotated as the log section says
+func openLog(path string) (*logging.File, error) {
+	items := settings.LogSettings().Items
+	return logging.OpenFile(path, items.MaxSize*1024*1024, time.Duration(items.RotateInterval)*time.Hour, items.MaxFiles)
+}
+
+// reopen opens the log files again after logrotate moved them
+func reopen(l *log.Logger, files []*logging.File) {
+	for _, f := range files {
+		if err := f.Reopen(); err != nil {
+			l.Println("[ERROR] reopening the log file:", err.Error())
+		}
+	}
+	l.Println("reopened the log files")
+}
+
+// configureLogs sets the format and the levels of the log section
+// access is nil when the access log is disabled
+func configureLogs(logs, access *logging.Logger) {
+	items := settings.LogSettings().Items
+	logs.SetFormat(items.Format)
+	if access != nil {
+		access.SetFormat(items.Format)
+	}
+	logs.ResetLevels()
+	for _, subsystem := range []struct{ name, level string }{
+		{logging.Main, items.Level},
+		{logging.Handshake, items.HandshakeLevel},
+		{logging.Chunk, items.ChunkLevel},
+		{logging.AMF, items.AMFLevel},
+		{logging.Restream, items.RestreamLevel},
+		{logging.GRPC, items.GRPCLevel},
+	} {
+		// validated by settings, the empty ones use Level
+		if level, err := logging.ParseLevel(subsystem.level); err == nil {
+			logs.SetLevel(subsystem.name, level)
+		}
+	}
+}
+
+// subscribeWebhooks posts the events to every url of the webhooks section
+func subscribeWebhooks(l *log.Logger, dispatcher *events.Dispatcher) {
+	items := settings.WebhooksSettings().Items
+	var types []events.Type
+	for _, t := range strings.Split(items.Events, ",") {
+		if t = strings.TrimSpace(t); t != "" {
+			types = append(types, events.Type(t))
+		}
+	}
+	for _, endpoint := range strings.Split(items.URLs, ",") {
+		endpoint = strings.TrimSpace(endpoint)
+		if endpoint == "" {
+			continue
+		}
+		webhook := events.NewWebhook(l, endpoint, items.Secret, types,
+			time.Duration(items.Timeout)*time.Millisecond, items.Retries,
+			time.Duration(items.RetryBackoff)*time.Millisecond)
+		dispatcher.Subscribe("webhook", items.Queue, webhook.Handle)
+	}
+}
+
+// config checks a config file with the environment overrides without starting the server
+func config(path string, args []string) int {
+	if len(args) == 0 || args[0] != "check" {
+		fmt.Fprintln(os.Stderr, "usage: restream [-config conf/conf.ini] config check [conf/conf.ini]")
+		return 2
+	}
+	if len(args) > 1 {
+		path = args[1]
+	}
+	if err := settings.Load(path); err != nil {
+		fmt.Fprintln(os.Stderr, err.Error())
+		return 1
+	}
+	fmt.Println(path, "is valid")
+	return 0
+}
+
+// publish pushes a flv file to the server as if it was sent by an encoder
+func publish(args []string) int {
+	flags := flag.NewFlagSet("publish", flag.ExitOnError)
+	file := flags.String("file", "", "flv file to publish")
+	app := flags.String("app", "live", "app to publish to")
+	key := flags.String("key", "", "stream key to publish with")
+	loop := flags.Bool("loop", false, "restart the file when it ends")
+	host := flags.String("host", "127.0.0.1", "host of the restream server")
+	flags.Parse(args)
+	if *file == "" || *key == "" {
+		fmt.Fprintln(os.Stderr, "usage: restream publish -file <file.flv> -key <key> [-app live] [-loop]")
+		return 2
+	}
+
+	l := log.New(os.Stderr, "", log.Ltime|log.Ldate|log.LUTC)
+	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer cancel()
+
+	url := fmt.Sprintf("rtmp://%s:%d/%s/%s", *host, settings.ServerSettings().Items.Port, *app, *key)
+	if err := source.PublishFLV(ctx, l, *file, url, *loop); err != nil && err != context.Canceled {
+		l.Println(err.Error())
+		return 1
+	}
+	return 0
+}
+
+// token prints a stream name signed with the secret of the token section
+func token(args []string) int {
+	flags := flag.NewFlagSet("token", flag.ExitOnError)
+	key := flags.String("key", "", "stream key to sign")
+	expire := flags.Duration("expire", time.Hour, "how long the name is valid, 0 never expires")
+	app := flags.String("app", "", "only allow this app")
+	ip := flags.String("ip", "", "only allow these comma separated ips or ranges")
+	action := flags.String("action", "", "only allow publish or play")
+	once := flags.Bool("once", false, "the name can be used only once")
+	flags.Parse(args)
+	secret := settings.TokenSettings().Items.Secret
+	if *key == "" || secret == "" {
+		fmt.Fprintln(os.Stderr, "usage: restream token -key <key> [-expire 1h] [-app live] [-ip 10.0.0.0/8] [-action publish] [-once]")
+		fmt.Fprintln(os.Stderr, "Secret of the token section in conf/conf.ini must be set")
+		return 2
+	}
+
+	params := url.Values{}
+	if *expire > 0 {
+		params.Set("exp", strconv.FormatInt(time.Now().Add(*expire).Unix(), 10))
+	}
+	if *app != "" {
+		params.Set("app", *app)
+	}
+	if *ip != "" {
+		params.Set("ip", *ip)
+	}
+	if *action != "" {
+		params.Set("action", *action)
+	}
+	if *once {
+		if *expire <= 0 {
+			fmt.Fprintln(os.Stderr, "-once needs -expire")
+			return 2
+		}
+		b := make([]byte, 8)
+		rand.Read(b)
+		params.Set("nonce", hex.EncodeToString(b))
+	}
+	fmt.Println(auth.Sign(secret, *key, params))
+	return 0
 }
@@ -23,6 +23,7 @@ const _ = grpc.SupportPackageIsVersion7
 // For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
 type UsersInfoClient interface {
 	Get(ctx context.Context, in *UsersInfoRequest, opts ...grpc.CallOption) (*UsersInfoResponse, error)
+	Report(ctx context.Context, in *StreamEvent, opts ...grpc.CallOption) (*ReportResponse, error)
 }
 
 type usersInfoClient struct {
@@ -42,11 +43,21 @@ func (c *usersInfoClient) Get(ctx context.Context, in *UsersInfoRequest, opts ..
 	return out, nil
 }
 
+func (c *usersInfoClient) Report(ctx context.Context, in *StreamEvent, opts ...grpc.CallOption) (*ReportResponse, error) {
+	out := new(ReportResponse)
+	err := c.cc.Invoke(ctx, "/UsersInfo/Report", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
 // UsersInfoServer is the server API for UsersInfo service.
 // All implementations must embed UnimplementedUsersInfoServer
 // for forward compatibility
 type UsersInfoServer interface {
 	Get(context.Context, *UsersInfoRequest) (*UsersInfoResponse, error)
+	Report(context.Context, *StreamEvent) (*ReportResponse, error)
 	mustEmbedUnimplementedUsersInfoServer()
 }
 
@@ -57,6 +68,9 @@ type UnimplementedUsersInfoServer struct {
 func (UnimplementedUsersInfoServer) Get(context.Context, *UsersInfoRequest) (*UsersInfoResponse, error) {
 	return nil, status.Errorf(codes.Unimplemented, "method Get not implemented")
 }
+func (UnimplementedUsersInfoServer) Report(context.Context, *StreamEvent) (*ReportResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Report not implemented")
+}
 func (UnimplementedUsersInfoServer) mustEmbedUnimplementedUsersInfoServer() {}
 
 // UnsafeUsersInfoServer may be embedded to opt out of forward compatibility for this service.
@@ -88,6 +102,24 @@ func _UsersInfo_Get_Handler(srv interface{}, ctx context.Context, dec func(inter
 	return interceptor(ctx, in, info, handler)
 }
 
+func _UsersInfo_Report_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(StreamEvent)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(UsersInfoServer).Report(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/UsersInfo/Report",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(UsersInfoServer).Report(ctx, req.(*StreamEvent))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
 // UsersInfo_ServiceDesc is the grpc.ServiceDesc for UsersInfo service.
 // It's only intended for direct use with grpc.RegisterService,
 // and not to be introspected or modified (even as a copy)
@@ -99,6 +131,10 @@ var UsersInfo_ServiceDesc = grpc.ServiceDesc{
 			MethodName: "Get",
 			Handler:    _UsersInfo_Get_Handler,
 		},
+		{
+			MethodName: "Report",
+			Handler:    _UsersInfo_Report_Handler,
+		},
 	},
 	Streams:  []grpc.StreamDesc{},
 	Metadata: "usersinfo.proto",
@@ -0,0 +1,130 @@
+package record
+
+import (
+	"bufio"
+	"errors"
+	"io"
+	"math"
+	"os"
+	"sort"
+	"sync"
+
+	"github.com/nareix/joy4/format/mp4/mp4io"
+)
+
+// rewrites are the files which are rewritten in the background
+var rewrites sync.WaitGroup
+
+// Wait blocks until the files which are being rewritten are done
+func Wait() {
+	rewrites.Wait()
+}
+
+// rewriteFastStart rewrites the fragmented file at path with the samples of
+// tracks into a progressive mp4 with moov before mdat so it can be played
+// before it's fully downloaded, the recorder doesn't use tracks anymore
+// so it runs in the background
+func rewriteFastStart(path string, tracks []*mp4Track) error {
+	type sampleRef struct {
+		sample *mp4Sample
+		offset int64
+	}
+	var refs []sampleRef
+	var dataSize int64
+	for _, t := range tracks {
+		for i := range t.samples {
+			refs = append(refs, sampleRef{sample: &t.samples[i], offset: t.samples[i].offset})
+			dataSize += int64(t.samples[i].size)
+		}
+	}
+	if dataSize+8 > math.MaxUint32 {
+		return errors.New("mdat is too large for 32 bit chunk offsets")
+	}
+	// keep the samples in the interleaved order of the fragments
+	sort.Slice(refs, func(i, j int) bool { return refs[i].offset < refs[j].offset })
+
+	ftyp := fileType(false)
+	// the size of moov doesn't depend on the chunk offsets
+	// so the samples can be placed before it's created
+	offset := int64(len(ftyp) + movie(tracks, false).Len() + 8)
+	for _, ref := range refs {
+		ref.sample.offset = offset
+		offset += int64(ref.sample.size)
+	}
+	moov := movie(tracks, false)
+	moovBuf := make([]byte, moov.Len())
+	moov.Marshal(moovBuf)
+
+	src, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+
+	tmp := path + ".tmp"
+	dst, err := os.Create(tmp)
+	if err != nil {
+		return err
+	}
+	w := bufio.NewWriter(dst)
+	w.Write(ftyp)
+	w.Write(moovBuf)
+	w.Write(appendBoxHeader(nil, int(dataSize+8), "mdat"))
+	for _, ref := range refs {
+		if _, err := io.Copy(w, io.NewSectionReader(src, ref.offset, int64(ref.sample.size))); err != nil {
+			dst.Close()
+			os.Remove(tmp)
+			return err
+		}
+	}
+	if err := w.Flush(); err != nil {
+		dst.Close()
+		os.Remove(tmp)
+		return err
+	}
+	if err := dst.Close(); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+// fillSampleTable sets the sample table of the track from its samples
+// every sample is placed in its own chunk
+func fillSampleTable(st *mp4io.SampleTable, t *mp4Track) {
+	st.SampleToChunk.Entries = []mp4io.SampleToChunkEntry{
+		{
+			FirstChunk:      1,
+			SamplesPerChunk: 1,
+			SampleDescId:    1,
+		},
+	}
+	if t.video {
+		st.SyncSample = &mp4io.SyncSample{}
+		st.CompositionOffset = &mp4io.CompositionOffset{}
+	}
+
+	var stts *mp4io.TimeToSampleEntry
+	var ctts *mp4io.CompositionOffsetEntry
+	for i, s := range t.samples {
+		if stts == nil || stts.Duration != s.duration {
+			st.TimeToSample.Entries = append(st.TimeToSample.Entries, mp4io.TimeToSampleEntry{Duration: s.duration})
+			stts = &st.TimeToSample.Entries[len(st.TimeToSample.Entries)-1]
+		}
+		stts.Count++
+
+		if t.video {
+			if s.keyFrame {
+				st.SyncSample.Entries = append(st.SyncSample.Entries, uint32(i+1))
+			}
+			if ctts == nil || ctts.Offset != uint32(s.cts) {
+				st.CompositionOffset.Entries = append(st.CompositionOffset.Entries, mp4io.CompositionOffsetEntry{Offset: uint32(s.cts)})
+				ctts = &st.CompositionOffset.Entries[len(st.CompositionOffset.Entries)-1]
+			}
+			ctts.Count++
+		}
+
+		st.SampleSize.Entries = append(st.SampleSize.Entries, s.size)
+		st.ChunkOffset.Entries = append(st.ChunkOffset.Entries, uint32(s.offset))
+	}
+}
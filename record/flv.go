@@ -0,0 +1,245 @@
+package record
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"log"
+	"math"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/alipourhabibi/restream/amf"
+	"github.com/nareix/joy4/format/flv/flvio"
+)
+
+// FLVRecorder writes a publish session into one or more flv files
+type FLVRecorder struct {
+	log    *log.Logger
+	opts   Options
+	file   *os.File
+	writer *bufio.Writer
+	part   int
+	size   int64
+	base   uint32
+	last   uint32
+
+	metaData    flvio.AMFECMAArray
+	audioHeader []byte
+	videoHeader []byte
+	hasVideo    bool
+
+	// offsets of the duration and filesize values of onMetaData
+	// which are rewritten when the file is closed
+	durationOffset int64
+	filesizeOffset int64
+
+	buf [flvio.TagHeaderLength]byte
+}
+
+// NewFLVRecorder returns a recorder which creates its first file
+// when the first message is written
+func NewFLVRecorder(log *log.Logger, opts Options) *FLVRecorder {
+	return &FLVRecorder{
+		log:  log,
+		opts: opts,
+	}
+}
+
+// Write writes the message as a flv tag
+// sequence headers and metadata are kept to be repeated in every part
+func (r *FLVRecorder) Write(msgType uint8, timestamp uint32, payload []byte) error {
+	switch {
+	case msgType == 18:
+		metaData := parseMetaData(payload)
+		if metaData == nil {
+			return nil
+		}
+		r.metaData = metaData
+		if r.file == nil {
+			// it will be written as the first tag of the file
+			return nil
+		}
+		data, _ := amf.Encode("onMetaData", metaData)
+		return r.writeTag(msgType, r.last, data)
+	case isSequenceHeader(msgType, payload):
+		if msgType == 8 {
+			r.audioHeader = append([]byte(nil), payload...)
+		} else {
+			r.videoHeader = append([]byte(nil), payload...)
+		}
+		if r.file == nil {
+			return nil
+		}
+		return r.writeTag(msgType, r.last, payload)
+	}
+
+	if msgType == 9 {
+		r.hasVideo = true
+	}
+
+	// split only on keyframes so every part starts decodable
+	if r.file != nil && r.shouldSplit() && (!r.hasVideo || (msgType == 9 && isKeyFrame(payload))) {
+		if err := r.Close(); err != nil {
+			return err
+		}
+	}
+	if r.file == nil {
+		if err := r.open(timestamp); err != nil {
+			return err
+		}
+	}
+
+	var ts uint32
+	if timestamp > r.base {
+		ts = timestamp - r.base
+	}
+	// flv players expect timestamps to never go backward
+	if ts < r.last {
+		ts = r.last
+	}
+	r.last = ts
+	return r.writeTag(msgType, ts, payload)
+}
+
+// Close patches duration and filesize of onMetaData and closes the file
+func (r *FLVRecorder) Close() error {
+	if r.file == nil {
+		return nil
+	}
+	file := r.file
+	r.file = nil
+
+	if err := r.writer.Flush(); err != nil {
+		file.Close()
+		return err
+	}
+
+	var b [8]byte
+	binary.BigEndian.PutUint64(b[:], math.Float64bits(float64(r.last)/1000))
+	if _, err := file.WriteAt(b[:], r.durationOffset); err != nil {
+		file.Close()
+		return err
+	}
+	binary.BigEndian.PutUint64(b[:], math.Float64bits(float64(r.size)))
+	if _, err := file.WriteAt(b[:], r.filesizeOffset); err != nil {
+		file.Close()
+		return err
+	}
+
+	r.log.Printf("recorded %s duration %dms size %d bytes\n", r.opts.logName(file.Name()), r.last, r.size)
+	if err := file.Close(); err != nil {
+		return err
+	}
+	r.opts.finished(file.Name(), r.last, r.size)
+	return nil
+}
+
+func (r *FLVRecorder) shouldSplit() bool {
+	if r.opts.MaxSize > 0 && r.size >= r.opts.MaxSize {
+		return true
+	}
+	if r.opts.MaxDuration > 0 && time.Duration(r.last)*time.Millisecond >= r.opts.MaxDuration {
+		return true
+	}
+	return false
+}
+
+// open creates the next part and writes the flv header, onMetaData
+// and the sequence headers to it
+func (r *FLVRecorder) open(timestamp uint32) error {
+	r.part++
+	path := r.opts.path(r.part, ".flv")
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_RDWR|os.O_TRUNC, 0644)
+	if err != nil {
+		return err
+	}
+	r.file = file
+	r.writer = bufio.NewWriter(file)
+	r.size = 0
+	r.base = timestamp
+	r.last = 0
+
+	header := make([]byte, flvio.FileHeaderLength+4)
+	flvio.FillFileHeader(header, flvio.FILE_HAS_AUDIO|flvio.FILE_HAS_VIDEO)
+	if _, err := r.writer.Write(header); err != nil {
+		return err
+	}
+	r.size += int64(len(header))
+
+	metaData := flvio.AMFECMAArray{}
+	for k, v := range r.metaData {
+		metaData[k] = v
+	}
+	// placeholders which are set when the file is closed
+	metaData["duration"] = float64(0)
+	metaData["filesize"] = float64(0)
+	data, _ := amf.Encode("onMetaData", metaData)
+	dataOffset := r.size + flvio.TagHeaderLength
+	r.durationOffset = dataOffset + amfNumberOffset(data, "duration")
+	r.filesizeOffset = dataOffset + amfNumberOffset(data, "filesize")
+	if err := r.writeTag(18, 0, data); err != nil {
+		return err
+	}
+
+	if r.videoHeader != nil {
+		if err := r.writeTag(9, 0, r.videoHeader); err != nil {
+			return err
+		}
+	}
+	if r.audioHeader != nil {
+		if err := r.writeTag(8, 0, r.audioHeader); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (r *FLVRecorder) writeTag(tagType uint8, timestamp uint32, data []byte) error {
+	n := flvio.FillTagHeader(r.buf[:], tagType, len(data), int32(timestamp))
+	if _, err := r.writer.Write(r.buf[:n]); err != nil {
+		return err
+	}
+	if _, err := r.writer.Write(data); err != nil {
+		return err
+	}
+	n = flvio.FillTagTrailer(r.buf[:], len(data))
+	if _, err := r.writer.Write(r.buf[:n]); err != nil {
+		return err
+	}
+	r.size += int64(flvio.TagHeaderLength + len(data) + flvio.TagTrailerLength)
+	return nil
+}
+
+// parseMetaData returns the object of an @setDataFrame or onMetaData message
+func parseMetaData(payload []byte) flvio.AMFECMAArray {
+	for len(payload) > 0 {
+		val, n, err := flvio.ParseAMF0Val(payload)
+		if err != nil {
+			return nil
+		}
+		payload = payload[n:]
+		switch v := val.(type) {
+		case flvio.AMFECMAArray:
+			return v
+		case flvio.AMFMap:
+			return flvio.AMFECMAArray(v)
+		}
+	}
+	return nil
+}
+
+// amfNumberOffset returns the offset of the number value of key in
+// an encoded AMF0 object
+func amfNumberOffset(data []byte, key string) int64 {
+	marker := make([]byte, 2+len(key)+1)
+	binary.BigEndian.PutUint16(marker, uint16(len(key)))
+	copy(marker[2:], key)
+	// 0x00 is the number type marker
+	marker[len(marker)-1] = 0x00
+	return int64(bytes.Index(data, marker) + len(marker))
+}
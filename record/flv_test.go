@@ -0,0 +1,222 @@
+package record
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/hex"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/alipourhabibi/restream/amf"
+	"github.com/nareix/joy4/format/flv/flvio"
+)
+
+var discard = log.New(io.Discard, "", 0)
+
+// sequence headers of a 1080p h264 high profile and 44.1kHz stereo aac stream
+var (
+	videoHeader, _ = hex.DecodeString("170000000001640028ffe1001e67640028acd940780227e5c05a808080a0000003002000000781e30632c001000668ebe3cb22c0")
+	audioHeader, _ = hex.DecodeString("af001210")
+)
+
+type message struct {
+	typ       uint8
+	timestamp uint32
+	payload   []byte
+}
+
+// session returns the messages of a publisher which starts at base and
+// sends duration milliseconds of 25fps video with a keyframe every second
+// and an audio frame every 20ms after the video frame of its time,
+// the payload of each frame is unique
+func session(base, duration uint32) []message {
+	metaData, _ := amf.Encode("@setDataFrame", "onMetaData", flvio.AMFMap{"width": float64(1920), "height": float64(1080)})
+	messages := []message{
+		{18, base, metaData},
+		{9, base, videoHeader},
+		{8, base, audioHeader},
+	}
+	for t := uint32(0); t < duration; t += 20 {
+		if t%40 == 0 {
+			frame := byte(0x27)
+			if t%1000 == 0 {
+				frame = 0x17
+			}
+			payload := []byte{frame, 1, 0, 0, 0, 0, 0, 0, 3, 0x41, byte(t >> 8), byte(t)}
+			messages = append(messages, message{9, base + t, payload})
+		}
+		messages = append(messages, message{8, base + t, []byte{0xaf, 1, 0x21, byte(t >> 8), byte(t)}})
+	}
+	return messages
+}
+
+// media returns the audio and video frames of messages without their headers
+func media(messages []message) []message {
+	var frames []message
+	for _, m := range messages {
+		if m.typ != 18 && !isSequenceHeader(m.typ, m.payload) {
+			frames = append(frames, m)
+		}
+	}
+	return frames
+}
+
+// finishedFiles collects the files a recorder finished
+type finishedFiles struct {
+	mu    sync.Mutex
+	paths []string
+	sizes []int64
+}
+
+func (f *finishedFiles) add(path string, duration uint32, size int64) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.paths = append(f.paths, path)
+	f.sizes = append(f.sizes, size)
+}
+
+// readFLV returns the tags of the flv file at path
+func readFLV(t *testing.T, path string) []message {
+	t.Helper()
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := flvio.ParseFileHeader(data); err != nil {
+		t.Fatal(err)
+	}
+	data = data[flvio.FileHeaderLength+4:]
+	var tags []message
+	for len(data) > 0 {
+		if len(data) < flvio.TagHeaderLength {
+			t.Fatalf("%d bytes of a tag header", len(data))
+		}
+		size := int(data[1])<<16 | int(data[2])<<8 | int(data[3])
+		timestamp := uint32(data[7])<<24 | uint32(data[4])<<16 | uint32(data[5])<<8 | uint32(data[6])
+		end := flvio.TagHeaderLength + size + flvio.TagTrailerLength
+		if len(data) < end {
+			t.Fatalf("tag of %d bytes is cut at %d", size, len(data))
+		}
+		if previous := binary.BigEndian.Uint32(data[end-4:]); int(previous) != flvio.TagHeaderLength+size {
+			t.Fatalf("previous tag size %d, want %d", previous, flvio.TagHeaderLength+size)
+		}
+		tags = append(tags, message{data[0], timestamp, data[flvio.TagHeaderLength : end-4]})
+		data = data[end:]
+	}
+	return tags
+}
+
+// onMetaData returns the object of a onMetaData tag
+func onMetaData(t *testing.T, payload []byte) flvio.AMFECMAArray {
+	t.Helper()
+	name, n, err := flvio.ParseAMF0Val(payload)
+	if err != nil || name != "onMetaData" {
+		t.Fatalf("data tag %v is not onMetaData: %v", name, err)
+	}
+	val, _, err := flvio.ParseAMF0Val(payload[n:])
+	if err != nil {
+		t.Fatal(err)
+	}
+	switch v := val.(type) {
+	case flvio.AMFECMAArray:
+		return v
+	case flvio.AMFMap:
+		return flvio.AMFECMAArray(v)
+	}
+	t.Fatalf("onMetaData is a %T", val)
+	return nil
+}
+
+func TestFLVRecorder(t *testing.T) {
+	tests := []struct {
+		name        string
+		maxSize     int64
+		maxDuration time.Duration
+		parts       int
+	}{
+		{"one file", 0, 0, 1},
+		{"split by duration", 0, 900 * time.Millisecond, 3},
+		{"split by size on keyframes", 1, 0, 3},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			files := &finishedFiles{}
+			r := NewFLVRecorder(discard, Options{
+				Dir:         dir,
+				FileName:    "{app}/{key}_{part}",
+				App:         "live",
+				Key:         "../key",
+				MaxSize:     tt.maxSize,
+				MaxDuration: tt.maxDuration,
+				Finished:    files.add,
+			})
+			messages := session(5000, 3000)
+			for _, m := range messages {
+				if err := r.Write(m.typ, m.timestamp, m.payload); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if err := r.Close(); err != nil {
+				t.Fatal(err)
+			}
+			if len(files.paths) != tt.parts {
+				t.Fatalf("recorded %d files, want %d", len(files.paths), tt.parts)
+			}
+
+			var recorded []message
+			for i, path := range files.paths {
+				if want := filepath.Join(dir, "live", "__key_"+strconv.Itoa(i+1)+".flv"); path != want {
+					t.Errorf("part %d is %s, want %s", i+1, path, want)
+				}
+				tags := readFLV(t, path)
+				if len(tags) < 4 || tags[0].typ != 18 || !bytes.Equal(tags[1].payload, videoHeader) || !bytes.Equal(tags[2].payload, audioHeader) {
+					t.Fatalf("part %d doesn't start with onMetaData and the sequence headers", i+1)
+				}
+				frames := tags[3:]
+				if frames[0].timestamp != 0 {
+					t.Errorf("part %d starts at %d", i+1, frames[0].timestamp)
+				}
+				if frames[0].typ != 9 || !isKeyFrame(frames[0].payload) {
+					t.Errorf("part %d doesn't start with a keyframe", i+1)
+				}
+
+				metaData := onMetaData(t, tags[0].payload)
+				last := frames[len(frames)-1].timestamp
+				if metaData["duration"] != float64(last)/1000 {
+					t.Errorf("part %d duration = %v, want %v", i+1, metaData["duration"], float64(last)/1000)
+				}
+				if metaData["filesize"] != float64(files.sizes[i]) {
+					t.Errorf("part %d filesize = %v, want %d", i+1, metaData["filesize"], files.sizes[i])
+				}
+				if info, _ := os.Stat(path); info.Size() != files.sizes[i] {
+					t.Errorf("part %d is %d bytes, finished with %d", i+1, info.Size(), files.sizes[i])
+				}
+				if metaData["width"] != float64(1920) {
+					t.Errorf("part %d lost the metadata of the publisher: %v", i+1, metaData)
+				}
+
+				recorded = append(recorded, frames...)
+			}
+
+			want := media(messages)
+			if len(recorded) != len(want) {
+				t.Fatalf("recorded %d frames, want %d", len(recorded), len(want))
+			}
+			for i := range want {
+				if recorded[i].typ != want[i].typ || !bytes.Equal(recorded[i].payload, want[i].payload) {
+					t.Fatalf("frame %d = %v, want %v", i, recorded[i], want[i])
+				}
+				if tt.parts == 1 && recorded[i].timestamp != want[i].timestamp-5000 {
+					t.Fatalf("frame %d at %d, want %d", i, recorded[i].timestamp, want[i].timestamp-5000)
+				}
+			}
+		})
+	}
+}
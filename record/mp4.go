@@ -0,0 +1,557 @@
+package record
+
+import (
+	"bufio"
+	"encoding/binary"
+	"log"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/nareix/joy4/codec/aacparser"
+	"github.com/nareix/joy4/codec/h264parser"
+	"github.com/nareix/joy4/format/mp4/mp4io"
+)
+
+// every track uses milliseconds which is the unit of rtmp timestamps
+const mp4TimeScale = 1000
+
+// sample flags of trun
+const (
+	syncSampleFlags    = 0x02000000
+	nonSyncSampleFlags = 0x01010000
+)
+
+// audio only recordings are fragmented every second
+const audioFragmentDuration = 1000
+
+type mp4Sample struct {
+	dts      uint32
+	duration uint32
+	cts      int32
+	size     uint32
+	keyFrame bool
+	// offset of the sample data in the fragmented file
+	offset int64
+}
+
+type mp4Track struct {
+	id         uint32
+	video      bool
+	codec      interface{}
+	pending    []mp4Sample
+	pendingBuf [][]byte
+	samples    []mp4Sample
+	baseTime   uint32
+	lastDTS    uint32
+	started    bool
+}
+
+// MP4Recorder writes a publish session into fragmented mp4 files
+// every fragment is complete when it's written so the file stays
+// playable if the server is killed, when the session ends normally
+// the file can be rewritten into a progressive mp4 with moov at the start
+type MP4Recorder struct {
+	log       *log.Logger
+	opts      Options
+	fastStart bool
+
+	file     *os.File
+	writer   *bufio.Writer
+	part     int
+	size     int64
+	base     uint32
+	last     uint32
+	sequence uint32
+
+	videoHeader []byte
+	audioHeader []byte
+	video       *mp4Track
+	audio       *mp4Track
+	tracks      []*mp4Track
+}
+
+// NewMP4Recorder returns a recorder which creates its first file
+// when the first media message after the sequence headers is written
+func NewMP4Recorder(log *log.Logger, opts Options, fastStart bool) *MP4Recorder {
+	return &MP4Recorder{
+		log:       log,
+		opts:      opts,
+		fastStart: fastStart,
+	}
+}
+
+// Write buffers the message into the current fragment and writes the
+// fragment on the next keyframe
+func (r *MP4Recorder) Write(msgType uint8, timestamp uint32, payload []byte) error {
+	if msgType != 8 && msgType != 9 {
+		return nil
+	}
+	if isSequenceHeader(msgType, payload) {
+		if msgType == 8 {
+			r.audioHeader = append([]byte(nil), payload...)
+		} else {
+			r.videoHeader = append([]byte(nil), payload...)
+		}
+		return nil
+	}
+
+	keyFrame := msgType == 8 || isKeyFrame(payload)
+	if r.file != nil && (r.video == nil || msgType == 9 && keyFrame) && r.shouldSplit() {
+		if err := r.Close(); err != nil {
+			return err
+		}
+	}
+	if r.file == nil {
+		if msgType == 9 && !keyFrame {
+			// wait for a keyframe to start the file with
+			return nil
+		}
+		if err := r.open(timestamp); err != nil {
+			return err
+		}
+	}
+
+	track := r.audio
+	data := payload
+	var cts int32
+	if msgType == 9 {
+		track = r.video
+		// FrameType|CodecID, AVCPacketType and 24 bit CompositionTime
+		if len(payload) < 5 {
+			return nil
+		}
+		cts = int32(payload[2])<<16 | int32(payload[3])<<8 | int32(payload[4])
+		// sign extend the 24 bit value
+		cts = cts << 8 >> 8
+		data = payload[5:]
+	} else if len(payload) > 2 {
+		// SoundFormat|SoundRate|SoundSize|SoundType and AACPacketType
+		data = payload[2:]
+	}
+	if track == nil {
+		return nil
+	}
+
+	var ts uint32
+	if timestamp > r.base {
+		ts = timestamp - r.base
+	}
+	// audio and video are interleaved loosely so only the
+	// timestamps of the same track have to be increasing
+	if ts < track.lastDTS {
+		ts = track.lastDTS
+	}
+	track.lastDTS = ts
+	if ts > r.last {
+		r.last = ts
+	}
+
+	// a new fragment starts on every video keyframe
+	// or every second when there is no video
+	if r.video != nil && track == r.video && keyFrame && r.hasPending() {
+		if err := r.writeFragment(ts); err != nil {
+			return err
+		}
+	} else if r.video == nil && ts-r.audio.baseTime >= audioFragmentDuration && r.hasPending() {
+		if err := r.writeFragment(ts); err != nil {
+			return err
+		}
+	}
+
+	if n := len(track.pending); n > 0 {
+		track.pending[n-1].duration = ts - track.pending[n-1].dts
+	}
+	if !track.started {
+		track.baseTime = ts
+		track.started = true
+	}
+	track.pending = append(track.pending, mp4Sample{
+		dts:      ts,
+		cts:      cts,
+		size:     uint32(len(data)),
+		keyFrame: keyFrame,
+	})
+	track.pendingBuf = append(track.pendingBuf, append([]byte(nil), data...))
+	return nil
+}
+
+// Close writes the remaining samples and rewrites the file to a
+// progressive mp4 when fast start is enabled
+func (r *MP4Recorder) Close() error {
+	if r.file == nil {
+		return nil
+	}
+	err := r.writeFragment(r.last)
+	if flushErr := r.writer.Flush(); err == nil {
+		err = flushErr
+	}
+	file := r.file
+	r.file = nil
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return err
+	}
+
+	path, tracks, duration, size := file.Name(), r.tracks, r.last, r.size
+	if !r.fastStart {
+		r.finished(path, duration, size)
+		return nil
+	}
+	// the copy of a large file would block the publisher and the next part
+	rewrites.Add(1)
+	go func() {
+		defer rewrites.Done()
+		if err := rewriteFastStart(path, tracks); err != nil {
+			// the fragmented file is still playable
+			r.log.Printf("[ERROR] faststart %s: %s\n", r.opts.logName(path), r.opts.logName(err.Error()))
+		}
+		r.finished(path, duration, size)
+	}()
+	return nil
+}
+
+func (r *MP4Recorder) finished(path string, duration uint32, size int64) {
+	r.log.Printf("recorded %s duration %dms size %d bytes\n", r.opts.logName(path), duration, size)
+	r.opts.finished(path, duration, size)
+}
+
+func (r *MP4Recorder) shouldSplit() bool {
+	if r.opts.MaxSize > 0 && r.size >= r.opts.MaxSize {
+		return true
+	}
+	if r.opts.MaxDuration > 0 && time.Duration(r.last)*time.Millisecond >= r.opts.MaxDuration {
+		return true
+	}
+	return false
+}
+
+func (r *MP4Recorder) hasPending() bool {
+	for _, t := range r.tracks {
+		if len(t.pending) > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// open creates the next part and writes the init segment of it
+func (r *MP4Recorder) open(timestamp uint32) error {
+	r.tracks = nil
+	r.video = nil
+	r.audio = nil
+	if r.videoHeader != nil && len(r.videoHeader) > 5 {
+		codec, err := h264parser.NewCodecDataFromAVCDecoderConfRecord(r.videoHeader[5:])
+		if err != nil {
+			return err
+		}
+		r.video = &mp4Track{id: uint32(len(r.tracks) + 1), video: true, codec: codec}
+		r.tracks = append(r.tracks, r.video)
+	}
+	if r.audioHeader != nil && len(r.audioHeader) > 2 {
+		codec, err := aacparser.NewCodecDataFromMPEG4AudioConfigBytes(r.audioHeader[2:])
+		if err != nil {
+			return err
+		}
+		r.audio = &mp4Track{id: uint32(len(r.tracks) + 1), codec: codec}
+		r.tracks = append(r.tracks, r.audio)
+	}
+	if len(r.tracks) == 0 {
+		// only AAC and AVC can be muxed into mp4
+		return nil
+	}
+
+	r.part++
+	path := r.opts.path(r.part, ".mp4")
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_RDWR|os.O_TRUNC, 0644)
+	if err != nil {
+		return err
+	}
+	r.file = file
+	r.writer = bufio.NewWriter(file)
+	r.size = 0
+	r.base = timestamp
+	r.last = 0
+	r.sequence = 0
+
+	moov := movie(r.tracks, true)
+	b := make([]byte, moov.Len())
+	moov.Marshal(b)
+	if err := r.write(fileType(true), b); err != nil {
+		return err
+	}
+	return r.writer.Flush()
+}
+
+// writeFragment writes the pending samples of every track as moof and mdat
+// and flushes them to the file
+func (r *MP4Recorder) writeFragment(nextDTS uint32) error {
+	if !r.hasPending() {
+		return nil
+	}
+	r.sequence++
+
+	var trafs [][]byte
+	var dataSize int
+	for _, t := range r.tracks {
+		if len(t.pending) == 0 {
+			continue
+		}
+		// the last video frame lasts until the keyframe which starts the
+		// next fragment, audio frames have the same duration as the previous
+		lastSample := &t.pending[len(t.pending)-1]
+		if t.video && nextDTS > lastSample.dts {
+			lastSample.duration = nextDTS - lastSample.dts
+		} else if len(t.pending) > 1 {
+			lastSample.duration = t.pending[len(t.pending)-2].duration
+		}
+		trafs = append(trafs, trackFragment(t, 0))
+		for _, s := range t.pending {
+			dataSize += int(s.size)
+		}
+	}
+
+	moofSize := 8 + 16
+	for _, traf := range trafs {
+		moofSize += len(traf)
+	}
+
+	// now that the size of moof is known the data offsets can be set
+	trafs = trafs[:0]
+	dataOffset := moofSize + 8
+	for _, t := range r.tracks {
+		if len(t.pending) == 0 {
+			continue
+		}
+		trafs = append(trafs, trackFragment(t, int32(dataOffset)))
+		for _, s := range t.pending {
+			dataOffset += int(s.size)
+		}
+	}
+
+	moof := make([]byte, 0, moofSize)
+	moof = appendBoxHeader(moof, moofSize, "moof")
+	moof = appendBoxHeader(moof, 16, "mfhd")
+	moof = binary.BigEndian.AppendUint32(moof, 0)
+	moof = binary.BigEndian.AppendUint32(moof, r.sequence)
+	for _, traf := range trafs {
+		moof = append(moof, traf...)
+	}
+	mdat := appendBoxHeader(nil, 8+dataSize, "mdat")
+	if err := r.write(moof, mdat); err != nil {
+		return err
+	}
+
+	offset := r.size
+	for _, t := range r.tracks {
+		for i, s := range t.pending {
+			if err := r.write(t.pendingBuf[i]); err != nil {
+				return err
+			}
+			s.offset = offset
+			offset += int64(s.size)
+			if r.fastStart {
+				t.samples = append(t.samples, s)
+			}
+		}
+		t.pending = t.pending[:0]
+		t.pendingBuf = t.pendingBuf[:0]
+		t.started = false
+	}
+	return r.writer.Flush()
+}
+
+func (r *MP4Recorder) write(bufs ...[]byte) error {
+	for _, b := range bufs {
+		n, err := r.writer.Write(b)
+		r.size += int64(n)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// trackFragment returns the traf box of the pending samples of the track
+func trackFragment(t *mp4Track, dataOffset int32) []byte {
+	trunSize := 8 + 12 + 16*len(t.pending)
+	size := 8 + 16 + 20 + trunSize
+
+	b := make([]byte, 0, size)
+	b = appendBoxHeader(b, size, "traf")
+
+	// default-base-is-moof
+	b = appendBoxHeader(b, 16, "tfhd")
+	b = binary.BigEndian.AppendUint32(b, 0x020000)
+	b = binary.BigEndian.AppendUint32(b, t.id)
+
+	b = appendBoxHeader(b, 20, "tfdt")
+	b = binary.BigEndian.AppendUint32(b, 1<<24)
+	b = binary.BigEndian.AppendUint64(b, uint64(t.baseTime))
+
+	// version 1 for signed composition offsets and data-offset, duration,
+	// size, flags and composition offset for each sample
+	b = appendBoxHeader(b, trunSize, "trun")
+	b = binary.BigEndian.AppendUint32(b, 1<<24|0x000f01)
+	b = binary.BigEndian.AppendUint32(b, uint32(len(t.pending)))
+	b = binary.BigEndian.AppendUint32(b, uint32(dataOffset))
+	for _, s := range t.pending {
+		flags := uint32(syncSampleFlags)
+		if !s.keyFrame {
+			flags = nonSyncSampleFlags
+		}
+		b = binary.BigEndian.AppendUint32(b, s.duration)
+		b = binary.BigEndian.AppendUint32(b, s.size)
+		b = binary.BigEndian.AppendUint32(b, flags)
+		b = binary.BigEndian.AppendUint32(b, uint32(s.cts))
+	}
+	return b
+}
+
+func appendBoxHeader(b []byte, size int, typ string) []byte {
+	b = binary.BigEndian.AppendUint32(b, uint32(size))
+	return append(b, typ...)
+}
+
+// fileType returns the ftyp box
+func fileType(fragmented bool) []byte {
+	brands := []string{"isom", "iso2", "avc1", "mp41"}
+	if fragmented {
+		brands = append(brands, "iso6")
+	}
+	b := appendBoxHeader(nil, 16+4*len(brands), "ftyp")
+	b = append(b, "isom"...)
+	b = binary.BigEndian.AppendUint32(b, 0x200)
+	for _, brand := range brands {
+		b = append(b, brand...)
+	}
+	return b
+}
+
+// movie returns the moov box of tracks, for the fragmented file the sample
+// tables are empty and mvex tells the players that fragments follow
+func movie(tracks []*mp4Track, fragmented bool) *mp4io.Movie {
+	moov := &mp4io.Movie{
+		Header: &mp4io.MovieHeader{
+			TimeScale:       mp4TimeScale,
+			PreferredRate:   1,
+			PreferredVolume: 1,
+			Matrix:          [9]int32{0x10000, 0, 0, 0, 0x10000, 0, 0, 0, 0x40000000},
+			NextTrackId:     int32(len(tracks) + 1),
+		},
+	}
+	if fragmented {
+		moov.MovieExtend = &mp4io.MovieExtend{}
+	}
+	for _, t := range tracks {
+		trak := t.trackAtom()
+		if !fragmented {
+			fillSampleTable(trak.Media.Info.Sample, t)
+			duration := t.duration()
+			trak.Header.Duration = int32(duration)
+			trak.Media.Header.Duration = int32(duration)
+			if duration > uint32(moov.Header.Duration) {
+				moov.Header.Duration = int32(duration)
+			}
+		} else {
+			moov.MovieExtend.Tracks = append(moov.MovieExtend.Tracks, &mp4io.TrackExtend{
+				TrackId:              t.id,
+				DefaultSampleDescIdx: 1,
+			})
+		}
+		moov.Tracks = append(moov.Tracks, trak)
+	}
+	return moov
+}
+
+func (t *mp4Track) duration() uint32 {
+	if len(t.samples) == 0 {
+		return 0
+	}
+	s := t.samples[len(t.samples)-1]
+	return s.dts + s.duration - t.samples[0].dts
+}
+
+// trackAtom returns the trak box with an empty sample table
+func (t *mp4Track) trackAtom() *mp4io.Track {
+	sample := &mp4io.SampleTable{
+		SampleDesc:    &mp4io.SampleDesc{},
+		TimeToSample:  &mp4io.TimeToSample{},
+		SampleToChunk: &mp4io.SampleToChunk{},
+		SampleSize:    &mp4io.SampleSize{},
+		ChunkOffset:   &mp4io.ChunkOffset{},
+	}
+	trak := &mp4io.Track{
+		Header: &mp4io.TrackHeader{
+			TrackId: int32(t.id),
+			// Track enabled | Track in movie
+			Flags:  0x0003,
+			Matrix: [9]int32{0x10000, 0, 0, 0, 0x10000, 0, 0, 0, 0x40000000},
+		},
+		Media: &mp4io.Media{
+			Header: &mp4io.MediaHeader{
+				TimeScale: mp4TimeScale,
+				Language:  21956,
+			},
+			Info: &mp4io.MediaInfo{
+				Sample: sample,
+				Data: &mp4io.DataInfo{
+					Refer: &mp4io.DataRefer{
+						Url: &mp4io.DataReferUrl{
+							// Self reference
+							Flags: 0x000001,
+						},
+					},
+				},
+			},
+		},
+	}
+
+	switch codec := t.codec.(type) {
+	case h264parser.CodecData:
+		width, height := codec.Width(), codec.Height()
+		sample.SampleDesc.AVC1Desc = &mp4io.AVC1Desc{
+			DataRefIdx:           1,
+			HorizontalResolution: 72,
+			VorizontalResolution: 72,
+			Width:                int16(width),
+			Height:               int16(height),
+			FrameCount:           1,
+			Depth:                24,
+			ColorTableId:         -1,
+			Conf:                 &mp4io.AVC1Conf{Data: codec.AVCDecoderConfRecordBytes()},
+		}
+		trak.Media.Handler = &mp4io.HandlerRefer{
+			SubType: [4]byte{'v', 'i', 'd', 'e'},
+			Name:    []byte("Video Media Handler"),
+		}
+		trak.Media.Info.Video = &mp4io.VideoMediaInfo{
+			Flags: 0x000001,
+		}
+		trak.Header.TrackWidth = float64(width)
+		trak.Header.TrackHeight = float64(height)
+	case aacparser.CodecData:
+		sample.SampleDesc.MP4ADesc = &mp4io.MP4ADesc{
+			DataRefIdx:       1,
+			NumberOfChannels: int16(codec.ChannelLayout().Count()),
+			SampleSize:       int16(codec.SampleFormat().BytesPerSample()),
+			SampleRate:       float64(codec.SampleRate()),
+			Conf: &mp4io.ElemStreamDesc{
+				DecConfig: codec.MPEG4AudioConfigBytes(),
+			},
+		}
+		trak.Header.Volume = 1
+		trak.Header.AlternateGroup = 1
+		trak.Media.Handler = &mp4io.HandlerRefer{
+			SubType: [4]byte{'s', 'o', 'u', 'n'},
+			Name:    []byte("Sound Handler"),
+		}
+		trak.Media.Info.Sound = &mp4io.SoundMediaInfo{}
+	}
+	return trak
+}
@@ -0,0 +1,227 @@
+package record
+
+import (
+	"bytes"
+	"encoding/binary"
+	"os"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/nareix/joy4/format/mp4/mp4io"
+)
+
+// topBoxes returns the types of the top level boxes of the mp4 file at path
+// and the size of the data in its mdat boxes
+func topBoxes(t *testing.T, path string) ([]string, int64) {
+	t.Helper()
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var boxes []string
+	var mdat int64
+	for len(data) > 0 {
+		if len(data) < 8 {
+			t.Fatalf("%d bytes of a box header", len(data))
+		}
+		size := int(binary.BigEndian.Uint32(data))
+		if size < 8 || size > len(data) {
+			t.Fatalf("box %s of %d bytes in %d", data[4:8], size, len(data))
+		}
+		typ := string(data[4:8])
+		boxes = append(boxes, typ)
+		if typ == "mdat" {
+			mdat += int64(size - 8)
+		}
+		data = data[size:]
+	}
+	return boxes, mdat
+}
+
+// mp4Frame is a sample of a progressive mp4 with its time in milliseconds
+type mp4Frame struct {
+	time     uint32
+	keyFrame bool
+	data     []byte
+}
+
+// readMP4 returns the samples of the video and the audio track of the
+// progressive mp4 at path from its sample tables
+func readMP4(t *testing.T, path string) [2][]mp4Frame {
+	t.Helper()
+	file, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	atoms, err := mp4io.ReadFileAtoms(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var moov *mp4io.Movie
+	for _, atom := range atoms {
+		if m, ok := atom.(*mp4io.Movie); ok {
+			moov = m
+		}
+	}
+	if moov == nil || len(moov.Tracks) != 2 {
+		t.Fatalf("%s has no moov with 2 tracks", path)
+	}
+
+	var tracks [2][]mp4Frame
+	for i, trak := range moov.Tracks {
+		st := trak.Media.Info.Sample
+		if desc := st.SampleDesc.AVC1Desc; i == 0 && (desc == nil || desc.Width != 1920 || desc.Height != 1080) {
+			t.Fatalf("track 1 isn't the 1920x1080 video: %+v", desc)
+		}
+		if desc := st.SampleDesc.MP4ADesc; i == 1 && (desc == nil || desc.SampleRate != 44100 || desc.NumberOfChannels != 2) {
+			t.Fatalf("track 2 isn't the 44.1kHz stereo audio: %+v", desc)
+		}
+		if len(st.ChunkOffset.Entries) != len(st.SampleSize.Entries) {
+			t.Fatalf("track %d has %d chunks of %d samples", i+1, len(st.ChunkOffset.Entries), len(st.SampleSize.Entries))
+		}
+		var durations []uint32
+		for _, e := range st.TimeToSample.Entries {
+			for j := uint32(0); j < e.Count; j++ {
+				durations = append(durations, e.Duration)
+			}
+		}
+		if len(durations) != len(st.SampleSize.Entries) {
+			t.Fatalf("track %d has %d durations of %d samples", i+1, len(durations), len(st.SampleSize.Entries))
+		}
+		sync := make(map[int]bool)
+		if st.SyncSample != nil {
+			for _, n := range st.SyncSample.Entries {
+				sync[int(n)-1] = true
+			}
+		}
+		var dts uint32
+		for j, size := range st.SampleSize.Entries {
+			data := make([]byte, size)
+			if _, err := file.ReadAt(data, int64(st.ChunkOffset.Entries[j])); err != nil {
+				t.Fatal(err)
+			}
+			tracks[i] = append(tracks[i], mp4Frame{dts, st.SyncSample == nil || sync[j], data})
+			dts += durations[j]
+		}
+	}
+	return tracks
+}
+
+func TestMP4Recorder(t *testing.T) {
+	tests := []struct {
+		name        string
+		fastStart   bool
+		maxDuration time.Duration
+		parts       int
+	}{
+		{"fragmented", false, 0, 1},
+		{"faststart", true, 0, 1},
+		{"faststart split by duration", true, 900 * time.Millisecond, 3},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			files := &finishedFiles{}
+			r := NewMP4Recorder(discard, Options{
+				Dir:         t.TempDir(),
+				FileName:    "{key}_{part}",
+				Key:         "key",
+				MaxDuration: tt.maxDuration,
+				Finished:    files.add,
+			}, tt.fastStart)
+			messages := session(5000, 3000)
+			for _, m := range messages {
+				if err := r.Write(m.typ, m.timestamp, m.payload); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if err := r.Close(); err != nil {
+				t.Fatal(err)
+			}
+			Wait()
+			if len(files.paths) != tt.parts {
+				t.Fatalf("recorded %d files, want %d", len(files.paths), tt.parts)
+			}
+			// the parts are rewritten in the background and finish in any order
+			sort.Strings(files.paths)
+
+			// the data of the frames without their flv headers
+			var video, audio [][]byte
+			for _, m := range media(messages) {
+				if m.typ == 9 {
+					video = append(video, m.payload[5:])
+				} else {
+					audio = append(audio, m.payload[2:])
+				}
+			}
+
+			if !tt.fastStart {
+				boxes, mdat := topBoxes(t, files.paths[0])
+				// a fragment for every second of video
+				want := []string{"ftyp", "moov", "moof", "mdat", "moof", "mdat", "moof", "mdat"}
+				if !equalStrings(boxes, want) {
+					t.Errorf("boxes = %v, want %v", boxes, want)
+				}
+				if size := int64(len(bytes.Join(append(video, audio...), nil))); mdat != size {
+					t.Errorf("mdat has %d bytes, want %d", mdat, size)
+				}
+				return
+			}
+
+			var frames [2][]mp4Frame
+			for i, path := range files.paths {
+				boxes, _ := topBoxes(t, path)
+				if want := []string{"ftyp", "moov", "mdat"}; !equalStrings(boxes, want) {
+					t.Errorf("part %d boxes = %v, want %v", i+1, boxes, want)
+				}
+				if _, err := os.Stat(path + ".tmp"); !os.IsNotExist(err) {
+					t.Errorf("part %d left its temporary file", i+1)
+				}
+				tracks := readMP4(t, path)
+				if len(tracks[0]) == 0 || !tracks[0][0].keyFrame || tracks[0][0].time != 0 {
+					t.Errorf("part %d doesn't start with a keyframe at 0", i+1)
+				}
+				frames[0] = append(frames[0], tracks[0]...)
+				frames[1] = append(frames[1], tracks[1]...)
+			}
+
+			for i, want := range [][][]byte{video, audio} {
+				got := frames[i]
+				if len(got) != len(want) {
+					t.Fatalf("track %d has %d samples, want %d", i+1, len(got), len(want))
+				}
+				for j := range want {
+					if !bytes.Equal(got[j].data, want[j]) {
+						t.Fatalf("sample %d of track %d = % x, want % x", j, i+1, got[j].data, want[j])
+					}
+				}
+			}
+			if tt.parts == 1 {
+				// every frame keeps its time from the start of the file
+				for j, f := range frames[0] {
+					if f.time != uint32(j*40) || f.keyFrame != (j%25 == 0) {
+						t.Fatalf("video sample %d at %dms with keyframe %v", j, f.time, f.keyFrame)
+					}
+				}
+				for j, f := range frames[1] {
+					if f.time != uint32(j*20) {
+						t.Fatalf("audio sample %d at %dms", j, f.time)
+					}
+				}
+			}
+		})
+	}
+}
+
+func equalStrings(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
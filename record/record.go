@@ -0,0 +1,95 @@
+package record
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/alipourhabibi/restream/logging"
+)
+
+// Recorder writes the media messages of a publish session to disk
+type Recorder interface {
+	// Write takes a complete rtmp message of type audio(8), video(9)
+	// or data(18) with its absolute timestamp in milliseconds
+	Write(msgType uint8, timestamp uint32, payload []byte) error
+	// Close finalizes the current file
+	Close() error
+}
+
+// Options describes where a publish session should be recorded
+// and when the recording should be split into a new file
+// Finished is called with every file after it's closed
+type Options struct {
+	Dir         string
+	FileName    string
+	App         string
+	Key         string
+	Session     string
+	MaxSize     int64
+	MaxDuration time.Duration
+	Finished    func(path string, duration uint32, size int64)
+}
+
+func (o Options) finished(path string, duration uint32, size int64) {
+	if o.Finished != nil {
+		o.Finished(path, duration, size)
+	}
+}
+
+// replace path separators so user controlled values can't escape Dir
+var unsafeChars = strings.NewReplacer("/", "_", "\\", "_", "..", "_")
+
+// Sanitize returns s as it's written in the file names
+func Sanitize(s string) string {
+	return unsafeChars.Replace(s)
+}
+
+// path returns the file path of the given part of the recording
+// {app}, {key}, {date}, {session} and {part} in FileName are replaced
+// and ext is added to the end of it
+func (o Options) path(part int, ext string) string {
+	name := o.FileName
+	if name == "" {
+		name = "{app}_{key}_{date}_{session}_{part}"
+	}
+	name = strings.NewReplacer(
+		"{app}", unsafeChars.Replace(o.App),
+		"{key}", unsafeChars.Replace(o.Key),
+		"{date}", time.Now().UTC().Format("20060102-150405"),
+		"{session}", unsafeChars.Replace(o.Session),
+		"{part}", fmt.Sprintf("%d", part),
+	).Replace(name)
+	return filepath.Join(o.Dir, name+ext)
+}
+
+// logName returns path with the key replaced by its hash like the
+// other logs, the key is the credential of the publisher
+func (o Options) logName(path string) string {
+	if o.Key == "" {
+		return path
+	}
+	return strings.ReplaceAll(path, unsafeChars.Replace(o.Key), logging.Redact(o.Key))
+}
+
+// isSequenceHeader reports whether the payload is an AAC or AVC sequence header
+func isSequenceHeader(msgType uint8, payload []byte) bool {
+	if len(payload) < 2 {
+		return false
+	}
+	switch msgType {
+	case 8:
+		// SoundFormat 10 is AAC and AACPacketType 0 is the sequence header
+		return payload[0]>>4 == 10 && payload[1] == 0
+	case 9:
+		// CodecID 7 is AVC and AVCPacketType 0 is the sequence header
+		return payload[0]&0x0f == 7 && payload[1] == 0
+	}
+	return false
+}
+
+// isKeyFrame reports whether the video payload starts a new GOP
+func isKeyFrame(payload []byte) bool {
+	return len(payload) > 0 && payload[0]>>4 == 1
+}
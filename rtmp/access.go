@@ -0,0 +1,82 @@
+package rtmp
+
+import (
+	"errors"
+	"io"
+	"net"
+	"os"
+	"strconv"
+	"sync/atomic"
+	"time"
+)
+
+// countConn counts the bytes read from and written to a connection
+type countConn struct {
+	net.Conn
+	in  int64
+	out int64
+}
+
+func (c *countConn) Read(b []byte) (int, error) {
+	n, err := c.Conn.Read(b)
+	atomic.AddInt64(&c.in, int64(n))
+	return n, err
+}
+
+func (c *countConn) Write(b []byte) (int, error) {
+	n, err := c.Conn.Write(b)
+	atomic.AddInt64(&c.out, int64(n))
+	return n, err
+}
+
+// logAccess writes the line of the connection to the access log
+// err is why it stopped reading
+func (c *Connection) logAccess(start time.Time, err error) {
+	if c.access == nil {
+		return
+	}
+	role := "none"
+	switch atomic.LoadInt32(&c.role) {
+	case rolePublisher:
+		role = "publisher"
+	case rolePlayer:
+		role = "player"
+	}
+	var in, out int64
+	if cc, ok := c.Conn.(*countConn); ok {
+		in, out = atomic.LoadInt64(&cc.in), atomic.LoadInt64(&cc.out)
+	}
+	if err := c.access.Access(c.fields,
+		"start", start.UTC().Format(time.RFC3339),
+		"duration", time.Since(start).Round(time.Millisecond).String(),
+		"role", role,
+		"bytes_in", strconv.FormatInt(in, 10),
+		"bytes_out", strconv.FormatInt(out, 10),
+		"reason", c.closeReason(err),
+	); err != nil {
+		c.log.Println("[ERROR] access log:", err.Error())
+	}
+}
+
+// closeReason returns why the connection was closed, the server's
+// reasons come before the error of the closed connection
+func (c *Connection) closeReason(err error) string {
+	unlock := c.lockClients()
+	stopped := c.stopped
+	unlock()
+	switch {
+	case stopped != "":
+		return stopped
+	case c.closed != "":
+		return c.closed
+	case c.Context.isClosing():
+		return "shutdown"
+	case err == nil:
+		return "closed"
+	case errors.Is(err, io.EOF):
+		return "closed by client"
+	case errors.Is(err, os.ErrDeadlineExceeded):
+		return "timeout"
+	}
+	return err.Error()
+}
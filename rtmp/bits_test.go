@@ -0,0 +1,51 @@
+package rtmp
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestBitReader(t *testing.T) {
+	tests := []struct {
+		name string
+		data []byte
+		read func(r *bitReader) int64
+		want int64
+		err  bool
+	}{
+		{"bits", []byte{0xa5}, func(r *bitReader) int64 { return int64(r.bits(4)) }, 0xa, false},
+		{"ue 0", []byte{0x80}, func(r *bitReader) int64 { return int64(r.ue()) }, 0, false},
+		{"ue 3", []byte{0x20}, func(r *bitReader) int64 { return int64(r.ue()) }, 3, false},
+		{"ue 1919", []byte{0x00, 0x3c, 0x00}, func(r *bitReader) int64 { return int64(r.ue()) }, 1919, false},
+		{"se 1", []byte{0x40}, func(r *bitReader) int64 { return int64(r.se()) }, 1, false},
+		{"se -1", []byte{0x60}, func(r *bitReader) int64 { return int64(r.se()) }, -1, false},
+		{"past the end", []byte{0xff}, func(r *bitReader) int64 { return int64(r.bits(9)) }, 0, true},
+		{"ue without its one", []byte{0x00, 0x00}, func(r *bitReader) int64 { return int64(r.ue()) }, 0, true},
+		{"skip past the end", []byte{0xff}, func(r *bitReader) int64 { r.skip(9); return 0 }, 0, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := &bitReader{data: tt.data}
+			if got := tt.read(r); got != tt.want || r.err != tt.err {
+				t.Errorf("got %d with err %v, want %d with err %v", got, r.err, tt.want, tt.err)
+			}
+		})
+	}
+}
+
+func TestUnescape(t *testing.T) {
+	tests := []struct {
+		nal  []byte
+		want []byte
+	}{
+		{[]byte{0x67, 0x00, 0x00, 0x03, 0x01}, []byte{0x67, 0x00, 0x00, 0x01}},
+		{[]byte{0x00, 0x00, 0x03, 0x00, 0x00, 0x03}, []byte{0x00, 0x00, 0x00, 0x00}},
+		{[]byte{0x00, 0x03, 0x03}, []byte{0x00, 0x03, 0x03}},
+		{nil, []byte{}},
+	}
+	for _, tt := range tests {
+		if got := unescape(tt.nal); !bytes.Equal(got, tt.want) {
+			t.Errorf("unescape(% x) = % x, want % x", tt.nal, got, tt.want)
+		}
+	}
+}
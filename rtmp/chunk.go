@@ -56,7 +56,24 @@ func (c *Connection) createRtmpChunk(fmt uint8, csid uint32) *rtmpChunk {
 	return chunk
 }
 
-func (c Connection) create(chunk *rtmpChunk) [][]byte {
+// withFullHeader returns a copy of the received message with a fmt 0 header
+// and its absolute timestamp to be sent to the clients
+func (chunk *rtmpChunk) withFullHeader() *rtmpChunk {
+	return &rtmpChunk{
+		header: &header{
+			fmt:             0,
+			csid:            chunk.header.csid,
+			messageType:     chunk.header.messageType,
+			messageStreamID: chunk.header.messageStreamID,
+			timestamp:       chunk.clock,
+			length:          chunk.header.length,
+		},
+		clock:   chunk.clock,
+		payload: chunk.payload,
+	}
+}
+
+func (c *Connection) create(chunk *rtmpChunk) [][]byte {
 	basicHeader := chunk.createBasicHeader()
 	messageHeader := chunk.createMessageHeader()
 	extendedTimestamp := chunk.createExtendedTimestamp()
@@ -160,7 +177,7 @@ func (chunk rtmpChunk) createExtendedTimestamp() []byte {
 	return make([]byte, 0)
 }
 
-func (chunk rtmpChunk) createPaylaodArray(c Connection) [][]byte {
+func (chunk rtmpChunk) createPaylaodArray(c *Connection) [][]byte {
 	// check the number of chunks
 	totalChunks := int(math.Ceil(float64(float64(chunk.header.length) / float64(c.WriteMaxChunkSize))))
 
@@ -0,0 +1,361 @@
+package rtmp
+
+import (
+	"encoding/binary"
+	"fmt"
+
+	"github.com/alipourhabibi/restream/events"
+)
+
+// names of the CodecID of flv video tags
+var videoCodecs = map[byte]string{
+	2:  "h263",
+	3:  "screen",
+	4:  "vp6",
+	5:  "vp6a",
+	6:  "screen2",
+	7:  "h264",
+	12: "hevc",
+}
+
+// names of the SoundFormat of flv audio tags
+var audioCodecs = map[byte]string{
+	0:  "pcm",
+	1:  "adpcm",
+	2:  "mp3",
+	3:  "pcm",
+	4:  "nellymoser",
+	5:  "nellymoser",
+	6:  "nellymoser",
+	7:  "g711a",
+	8:  "g711u",
+	10: "aac",
+	11: "speex",
+	14: "mp3",
+}
+
+// names of the profile_idc of h264
+var h264Profiles = map[byte]string{
+	66:  "baseline",
+	77:  "main",
+	88:  "extended",
+	100: "high",
+	110: "high 10",
+	122: "high 4:2:2",
+	244: "high 4:4:4",
+}
+
+// names of the general_profile_idc of hevc
+var hevcProfiles = map[byte]string{
+	1: "main",
+	2: "main 10",
+	3: "main still picture",
+	4: "range extensions",
+}
+
+// chroma_format_idc of h264 and hevc
+var chromaFormats = []string{"4:0:0", "4:2:0", "4:2:2", "4:4:4"}
+
+// sampling frequencies of the AudioSpecificConfig by their index
+var aacSampleRates = []int{
+	96000, 88200, 64000, 48000, 44100, 32000,
+	24000, 22050, 16000, 12000, 11025, 8000, 7350,
+}
+
+// codecInfo describes the media of the publisher from its first messages
+func (c *Connection) codecInfo() *events.Codec {
+	codec := &events.Codec{}
+	if len(c.FirstVideo) > 0 {
+		codec.Video = videoCodecs[c.FirstVideo[0]&0x0f]
+		// AVCPacketType 0 is followed by the composition time and the decoder configuration record
+		if len(c.FirstVideo) > 5 && c.FirstVideo[1] == 0 {
+			switch c.FirstVideo[0] & 0x0f {
+			case 7:
+				parseAVCConfig(codec, c.FirstVideo[5:])
+			case 12:
+				parseHEVCConfig(codec, c.FirstVideo[5:])
+			}
+		}
+	}
+	if len(c.FirstAudio) > 0 {
+		codec.Audio = audioCodecs[c.FirstAudio[0]>>4]
+		// AACPacketType 0 is followed by the AudioSpecificConfig
+		if c.FirstAudio[0]>>4 == 10 && len(c.FirstAudio) > 2 && c.FirstAudio[1] == 0 {
+			parseAudioSpecificConfig(codec, c.FirstAudio[2:])
+		}
+	}
+	return codec
+}
+
+// parseAVCConfig reads the first sps of an AVCDecoderConfigurationRecord
+func parseAVCConfig(codec *events.Codec, record []byte) {
+	if len(record) < 8 || record[5]&0x1f == 0 {
+		return
+	}
+	size := int(binary.BigEndian.Uint16(record[6:]))
+	if len(record) < 8+size {
+		return
+	}
+	parseH264SPS(codec, unescape(record[8:8+size]))
+}
+
+// parseH264SPS reads a seq_parameter_set_rbsp of h264 with its nal header
+// up to the timing of the vui, the fields it doesn't know are skipped
+func parseH264SPS(codec *events.Codec, sps []byte) {
+	r := &bitReader{data: sps}
+	r.skip(8)
+	profile := byte(r.bits(8))
+	constraints := r.bits(8)
+	level := r.bits(8)
+	r.ue()
+
+	chroma, depth := uint32(1), uint32(8)
+	switch profile {
+	case 100, 110, 122, 244, 44, 83, 86, 118, 128, 138, 139, 134, 135:
+		chroma = r.ue()
+		if chroma == 3 {
+			r.skip(1)
+		}
+		depth = r.ue() + 8
+		r.ue()
+		r.skip(1)
+		if r.flag() {
+			lists := 8
+			if chroma == 3 {
+				lists = 12
+			}
+			for i := 0; i < lists; i++ {
+				if !r.flag() {
+					continue
+				}
+				size := 16
+				if i >= 6 {
+					size = 64
+				}
+				skipScalingList(r, size)
+			}
+		}
+	}
+
+	r.ue()
+	switch r.ue() {
+	case 0:
+		r.ue()
+	case 1:
+		r.skip(1)
+		r.se()
+		r.se()
+		for n := r.ue(); n > 0 && !r.err; n-- {
+			r.se()
+		}
+	}
+	r.ue()
+	r.skip(1)
+	widthMbs := r.ue() + 1
+	heightMaps := r.ue() + 1
+	frameMbsOnly := r.bits(1)
+	if frameMbsOnly == 0 {
+		r.skip(1)
+	}
+	r.skip(1)
+	var cropLeft, cropRight, cropTop, cropBottom uint32
+	if r.flag() {
+		cropLeft, cropRight, cropTop, cropBottom = r.ue(), r.ue(), r.ue(), r.ue()
+	}
+	if r.err || chroma > 3 {
+		return
+	}
+
+	codec.VideoProfile = h264Profiles[profile]
+	if profile == 66 && constraints&0x40 != 0 {
+		codec.VideoProfile = "constrained baseline"
+	}
+	codec.VideoLevel = fmt.Sprintf("%d.%d", level/10, level%10)
+	codec.Chroma = chromaFormats[chroma]
+	codec.BitDepth = int(depth)
+	cropX, cropY := uint32(1), 2-frameMbsOnly
+	switch chroma {
+	case 1:
+		cropX, cropY = 2, 2*cropY
+	case 2:
+		cropX = 2
+	}
+	codec.Width = int(widthMbs*16 - cropX*(cropLeft+cropRight))
+	codec.Height = int((2-frameMbsOnly)*heightMaps*16 - cropY*(cropTop+cropBottom))
+
+	if !r.flag() {
+		return
+	}
+	// vui_parameters up to the timing_info
+	if r.flag() && r.bits(8) == 255 {
+		r.skip(32)
+	}
+	if r.flag() {
+		r.skip(1)
+	}
+	if r.flag() {
+		r.skip(4)
+		if r.flag() {
+			r.skip(24)
+		}
+	}
+	if r.flag() {
+		r.ue()
+		r.ue()
+	}
+	if r.flag() {
+		units, scale := r.bits(32), r.bits(32)
+		if !r.err && units > 0 {
+			codec.FrameRate = float64(scale) / float64(2*units)
+		}
+	}
+}
+
+func skipScalingList(r *bitReader, size int) {
+	last, next := int32(8), int32(8)
+	for i := 0; i < size && !r.err; i++ {
+		if next != 0 {
+			next = (last + r.se() + 256) % 256
+		}
+		if next != 0 {
+			last = next
+		}
+	}
+}
+
+// parseHEVCConfig reads an HEVCDecoderConfigurationRecord and the size
+// of the pictures from its first sps
+func parseHEVCConfig(codec *events.Codec, record []byte) {
+	if len(record) < 23 {
+		return
+	}
+	profile, level := record[1]&0x1f, record[12]
+	codec.VideoProfile = hevcProfiles[profile]
+	if record[1]&0x20 != 0 {
+		codec.VideoProfile += " high tier"
+	}
+	codec.VideoLevel = fmt.Sprintf("%d.%d", level/30, level%30/3)
+	codec.Chroma = chromaFormats[record[16]&0x03]
+	codec.BitDepth = int(record[17]&0x07) + 8
+	// avgFrameRate is in frames per 256 seconds, 0 when it's not known
+	if rate := binary.BigEndian.Uint16(record[19:]); rate > 0 {
+		codec.FrameRate = float64(rate) / 256
+	}
+
+	data := record[23:]
+	for arrays := record[22]; arrays > 0 && len(data) >= 3; arrays-- {
+		nalType := data[0] & 0x3f
+		count := int(binary.BigEndian.Uint16(data[1:]))
+		data = data[3:]
+		for ; count > 0 && len(data) >= 2; count-- {
+			size := int(binary.BigEndian.Uint16(data))
+			if len(data) < 2+size {
+				return
+			}
+			// 33 is the sps
+			if nalType == 33 {
+				parseHEVCSPS(codec, unescape(data[2:2+size]))
+				return
+			}
+			data = data[2+size:]
+		}
+	}
+}
+
+// parseHEVCSPS reads the size of the pictures of an hevc sps with its nal header
+func parseHEVCSPS(codec *events.Codec, sps []byte) {
+	r := &bitReader{data: sps}
+	r.skip(16 + 4)
+	subLayers := int(r.bits(3))
+	r.skip(1)
+	// profile_tier_level
+	r.skip(96)
+	profilePresent := make([]bool, subLayers)
+	levelPresent := make([]bool, subLayers)
+	for i := 0; i < subLayers; i++ {
+		profilePresent[i] = r.flag()
+		levelPresent[i] = r.flag()
+	}
+	if subLayers > 0 {
+		r.skip(2 * (8 - subLayers))
+	}
+	for i := 0; i < subLayers; i++ {
+		if profilePresent[i] {
+			r.skip(88)
+		}
+		if levelPresent[i] {
+			r.skip(8)
+		}
+	}
+
+	r.ue()
+	chroma := r.ue()
+	if chroma == 3 {
+		r.skip(1)
+	}
+	width, height := r.ue(), r.ue()
+	if r.flag() {
+		left, right, top, bottom := r.ue(), r.ue(), r.ue(), r.ue()
+		cropX, cropY := uint32(1), uint32(1)
+		switch chroma {
+		case 1:
+			cropX, cropY = 2, 2
+		case 2:
+			cropX = 2
+		}
+		width -= cropX * (left + right)
+		height -= cropY * (top + bottom)
+	}
+	depth := r.ue() + 8
+	if r.err || chroma > 3 {
+		return
+	}
+	codec.Width = int(width)
+	codec.Height = int(height)
+	codec.Chroma = chromaFormats[chroma]
+	codec.BitDepth = int(depth)
+}
+
+// parseAudioSpecificConfig reads the object type, sample rate and channels
+// of aac, the rate of he-aac is the one of its sbr extension
+func parseAudioSpecificConfig(codec *events.Codec, config []byte) {
+	r := &bitReader{data: config}
+	objectType := audioObjectType(r)
+	rate := sampleRate(r)
+	channels := int(r.bits(4))
+	// sbr and ps are followed by the rate of the extension and the underlying type
+	if objectType == 5 || objectType == 29 {
+		rate = sampleRate(r)
+	}
+	if r.err {
+		return
+	}
+	codec.AudioObjectType = int(objectType)
+	codec.SampleRate = rate
+	// channelConfiguration 7 is 7.1 and 0 is in the program config element
+	switch {
+	case channels == 7:
+		codec.Channels = 8
+	case channels < 7:
+		codec.Channels = channels
+	}
+}
+
+func audioObjectType(r *bitReader) uint32 {
+	objectType := r.bits(5)
+	if objectType == 31 {
+		objectType = 32 + r.bits(6)
+	}
+	return objectType
+}
+
+func sampleRate(r *bitReader) int {
+	index := int(r.bits(4))
+	if index == 15 {
+		return int(r.bits(24))
+	}
+	if index < len(aacSampleRates) {
+		return aacSampleRates[index]
+	}
+	return 0
+}
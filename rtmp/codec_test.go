@@ -0,0 +1,193 @@
+package rtmp
+
+import (
+	"encoding/hex"
+	"testing"
+
+	"github.com/alipourhabibi/restream/events"
+)
+
+func mustHex(t *testing.T, s string) []byte {
+	t.Helper()
+	b, err := hex.DecodeString(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return b
+}
+
+// bitWriter builds the sequence headers which the tests parse
+type bitWriter struct {
+	data []byte
+	n    int
+}
+
+func (w *bitWriter) bits(v uint32, n int) {
+	for i := n - 1; i >= 0; i-- {
+		if w.n%8 == 0 {
+			w.data = append(w.data, 0)
+		}
+		w.data[len(w.data)-1] |= byte(v>>i&1) << (7 - w.n%8)
+		w.n++
+	}
+}
+
+func (w *bitWriter) ue(v uint32) {
+	v++
+	size := 0
+	for x := v; x > 1; x >>= 1 {
+		size++
+	}
+	w.bits(0, size)
+	w.bits(v, size+1)
+}
+
+// hevcSPS returns the sps of a picture of width and height with the
+// bottom cropped by crop lines of 4:2:0 and subLayers sub layers
+func hevcSPS(width, height, crop uint32, subLayers uint32) []byte {
+	w := &bitWriter{}
+	// nal header of type 33
+	w.bits(33<<9|1, 16)
+	w.bits(0, 4)
+	w.bits(subLayers, 3)
+	w.bits(1, 1)
+	// general profile_tier_level, main profile and level 4
+	w.bits(0x01, 8)
+	w.bits(0x60000000, 32)
+	w.bits(0x90000000, 32)
+	w.bits(0x0078, 24)
+	for i := uint32(0); i < subLayers; i++ {
+		// sub_layer_profile_present_flag and sub_layer_level_present_flag
+		w.bits(1, 1)
+		w.bits(1, 1)
+	}
+	if subLayers > 0 {
+		w.bits(0, int(2*(8-subLayers)))
+	}
+	for i := uint32(0); i < subLayers; i++ {
+		w.bits(0x01, 8)
+		w.bits(0x60000000, 32)
+		w.bits(0x900000, 24)
+		w.bits(0x1111, 24)
+		w.bits(0x5a, 8)
+	}
+	w.ue(0)
+	w.ue(1)
+	w.ue(width)
+	w.ue(height)
+	if crop > 0 {
+		w.bits(1, 1)
+		w.ue(0)
+		w.ue(0)
+		w.ue(0)
+		w.ue(crop / 2)
+	} else {
+		w.bits(0, 1)
+	}
+	w.ue(0)
+	w.ue(0)
+	w.bits(1, 1)
+	return w.data
+}
+
+// hevcVideo returns the first video message of hevc with sps in its
+// HEVCDecoderConfigurationRecord
+func hevcVideo(sps []byte) []byte {
+	record := []byte{
+		1,
+		// main tier, main profile
+		0x01,
+		0x60, 0, 0, 0,
+		0x90, 0, 0, 0, 0, 0,
+		// level 4
+		120,
+		0xf0, 0,
+		0xfc,
+		// 4:2:0, 8 bits
+		0xfd, 0xf8, 0xf8,
+		// 25 fps
+		0x19, 0x00,
+		0x0f,
+		1,
+		0x80 | 33, 0, 1, 0, byte(len(sps)),
+	}
+	record = append(record, sps...)
+	return append([]byte{0x1c, 0, 0, 0, 0}, record...)
+}
+
+func TestCodecInfo(t *testing.T) {
+	tests := []struct {
+		name  string
+		video string
+		audio string
+		hevc  []byte
+		want  events.Codec
+	}{
+		{
+			name:  "h264 high 1080p cropped from 1088 and aac lc",
+			video: "170000000001640028ffe1001e67640028acd940780227e5c05a808080a0000003002000000781e30632c001000668ebe3cb22c0",
+			audio: "af001210",
+			want: events.Codec{
+				Video: "h264", Width: 1920, Height: 1080, VideoProfile: "high", VideoLevel: "4.0",
+				FrameRate: 30, Chroma: "4:2:0", BitDepth: 8,
+				Audio: "aac", SampleRate: 44100, Channels: 2, AudioObjectType: 2,
+			},
+		},
+		{
+			name:  "he-aac with the rate of its sbr extension",
+			audio: "af002b1188",
+			want:  events.Codec{Audio: "aac", SampleRate: 48000, Channels: 2, AudioObjectType: 5},
+		},
+		{
+			name:  "aac 7.1",
+			audio: "af0011b8",
+			want:  events.Codec{Audio: "aac", SampleRate: 48000, Channels: 8, AudioObjectType: 2},
+		},
+		{
+			name:  "mp3 has no config",
+			audio: "2fff",
+			want:  events.Codec{Audio: "mp3"},
+		},
+		{
+			name:  "truncated avc config is only the codec",
+			video: "1700000000016400",
+			want:  events.Codec{Video: "h264"},
+		},
+		{
+			name:  "truncated sps keeps the fields empty",
+			video: "170000000001640028ffe1000667640028acd9",
+			want:  events.Codec{Video: "h264"},
+		},
+		{
+			name: "hevc main 1080p",
+			hevc: hevcVideo(hevcSPS(1920, 1088, 8, 0)),
+			want: events.Codec{
+				Video: "hevc", Width: 1920, Height: 1080, VideoProfile: "main", VideoLevel: "4.0",
+				FrameRate: 25, Chroma: "4:2:0", BitDepth: 8,
+			},
+		},
+		{
+			name: "hevc with a sub layer",
+			hevc: hevcVideo(hevcSPS(1280, 720, 0, 1)),
+			want: events.Codec{
+				Video: "hevc", Width: 1280, Height: 720, VideoProfile: "main", VideoLevel: "4.0",
+				FrameRate: 25, Chroma: "4:2:0", BitDepth: 8,
+			},
+		},
+		{
+			name: "no media",
+			want: events.Codec{},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := &Connection{FirstVideo: mustHex(t, tt.video), FirstAudio: mustHex(t, tt.audio)}
+			if tt.hevc != nil {
+				c.FirstVideo = tt.hevc
+			}
+			if got := *c.codecInfo(); got != tt.want {
+				t.Errorf("codecInfo() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
@@ -2,17 +2,27 @@ package rtmp
 
 import (
 	"bufio"
+	"bytes"
 	"context"
 	"encoding/binary"
-	"encoding/json"
-	"fmt"
+	"errors"
 	"io"
 	"log"
 	"net"
 	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
 
+	"github.com/alipourhabibi/restream/acl"
 	"github.com/alipourhabibi/restream/amf"
-	protos "github.com/alipourhabibi/restream/protos/usersinfo"
+	"github.com/alipourhabibi/restream/auth"
+	"github.com/alipourhabibi/restream/events"
+	"github.com/alipourhabibi/restream/logging"
+	"github.com/alipourhabibi/restream/record"
+	"github.com/alipourhabibi/restream/settings"
 	"github.com/nareix/joy4/format/flv/flvio"
 	"github.com/nareix/joy4/utils/bits/pio"
 )
@@ -38,8 +48,44 @@ type Channel struct {
 	ChannelName string
 	Send        chan []byte
 	Exit        chan bool
+	// closed by a player whose connection broke, the messages
+	// to it are dropped so its publisher isn't blocked
+	Done chan struct{}
+	// set for the destinations, the messages are dropped while their
+	// buffer is full and it's set to 1 so they skip to the next keyframe
+	dropped *int32
 }
 
+// send gives data to the receiver and reports false when it's gone
+// a destination which is dialing or stuck never blocks the publisher
+func (ch Channel) send(data []byte) bool {
+	if ch.dropped != nil {
+		select {
+		case ch.Send <- data:
+		default:
+			atomic.StoreInt32(ch.dropped, 1)
+		}
+		return true
+	}
+	select {
+	case ch.Send <- data:
+		return true
+	case <-ch.Done:
+		return false
+	}
+}
+
+// exit stops the receiver unless it's gone
+func (ch Channel) exit() {
+	select {
+	case ch.Exit <- true:
+	case <-ch.Done:
+	}
+}
+
+// ChannelName of the players, the destinations have their own names
+const playerChannel = "-1"
+
 type UserChannel struct {
 	Name string
 	URL  string
@@ -53,6 +99,8 @@ type Services struct {
 type Service struct {
 	Name    string    `json:"Name"`
 	Servers []Servers `json:"servers"`
+	// checked against the stream sent to its servers
+	Requirements *Requirements `json:"requirements,omitempty"`
 }
 
 type Servers struct {
@@ -62,21 +110,81 @@ type Servers struct {
 
 // For setting and getting Connection based on the streamKey
 type StreamContext struct {
-	sessions map[string]*Connection
+	mu        sync.Mutex
+	sessions  map[string]*Connection
+	fallbacks map[string]*fallback
+	failovers map[string]*failover
+	// public play names of the stream keys
+	plays map[string]string
+	// catalog of services/servers.json
+	services *Services
+	// set when the server is shutting down
+	closing int32
+	// destinations which are still sending
+	destinations int64
+	// publishers and players for the limits section
+	publishers int64
+	players    int64
 }
 
 func (ctx *StreamContext) set(key string, c *Connection) {
+	ctx.mu.Lock()
+	defer ctx.mu.Unlock()
 	ctx.sessions[key] = c
 }
 
 func (ctx *StreamContext) get(key string) *Connection {
+	ctx.mu.Lock()
+	defer ctx.mu.Unlock()
 	return ctx.sessions[key]
 }
 
+// delete removes the key if it still belongs to c
+func (ctx *StreamContext) delete(key string, c *Connection) {
+	ctx.mu.Lock()
+	defer ctx.mu.Unlock()
+	if ctx.sessions[key] == c {
+		delete(ctx.sessions, key)
+		for name, k := range ctx.plays {
+			if k == key {
+				delete(ctx.plays, name)
+			}
+		}
+	}
+}
+
+func (ctx *StreamContext) setPlay(name, key string) {
+	ctx.mu.Lock()
+	defer ctx.mu.Unlock()
+	ctx.plays[name] = key
+}
+
+// playKey returns the stream key of a public play name
+func (ctx *StreamContext) playKey(name string) string {
+	ctx.mu.Lock()
+	defer ctx.mu.Unlock()
+	return ctx.plays[name]
+}
+
+// catalog returns the services which new publishers are sent to
+func (ctx *StreamContext) catalog() *Services {
+	ctx.mu.Lock()
+	defer ctx.mu.Unlock()
+	return ctx.services
+}
+
 // Connection struct for each conneciton which holds its data
 // such as sending and recieving datas
 type Connection struct {
-	log               *log.Logger
+	// the loggers of the subsystems share the fields of the connection
+	// which get its app and stream key when they're known
+	log          *log.Logger
+	handshakeLog *log.Logger
+	chunkLog     *log.Logger
+	amfLog       *log.Logger
+	fields       *logging.Fields
+	// nil when the access log is disabled
+	access            *logging.Logger
 	Conn              net.Conn
 	Reader            *bufio.Reader
 	Writer            *bufio.Writer
@@ -96,31 +204,97 @@ type Connection struct {
 	AppName           string
 	ConnectionDone    bool
 	Streams           int
-	RPC               protos.UsersInfoClient
+	Auth              auth.Authenticator
 	GotFirstAudio     bool
 	GotFirstVideo     bool
 	Context           *StreamContext
+	SessionID         string
+	PlayName          string
+	recorders         []record.Recorder
+	// added to the timestamps sent to the clients so they
+	// continue from the fallback slate after a republish
+	tsOffset      int64
+	lastTimestamp uint32
+	streamID      uint32
+	resume        *fallback
+	Events        *events.Dispatcher
+	publishStart  time.Time
+	bytesIn       int64
+	codecReported bool
+	// primary or backup publisher of a stream key with failover
+	backup    bool
+	failover  *failover
+	switching bool
+	lastMedia time.Time
+	// guards Clients when the connection has no failover
+	clientsMu *sync.Mutex
+	// why the server ended the publisher, like kicked or shutdown
+	// such a publisher isn't replaced by its fallback or backup
+	stopped string
+	// set once the connection is a publisher or a player
+	role int32
+	// why the server closed a connection which isn't a publisher,
+	// like a rejected key, for the access log
+	closed string
+	// publishers or players count of the context which this
+	// connection is in, it's released when the connection ends
+	slot *int64
+	// last audio or video message for the IdleTimeout
+	mediaAt time.Time
+	// set under lockClients when the publisher closes, no client is added after it
+	ended bool
+	// of the media of a publisher
+	stats *stats
 }
 
 // Handle each connection recieved
 func (c *Connection) Handle() {
-	if err := c.handshake(); err != nil {
-		c.log.Println(err.Error())
+	start := time.Now()
+	var err error
+	defer func() { c.logAccess(start, err) }()
+	defer func() {
+		if c.slot != nil {
+			atomic.AddInt64(c.slot, -1)
+		}
+	}()
+
+	limits := settings.LimitsSettings().Items
+	if limits.HandshakeTimeout > 0 {
+		c.Conn.SetDeadline(time.Now().Add(time.Duration(limits.HandshakeTimeout) * time.Millisecond))
+	}
+	if err = c.handshake(); err != nil {
+		c.handshakeLog.Println("[WARNING] handshake failed:", err.Error())
 		return
 	}
-	if err := c.prepare(); err != nil {
-		c.log.Println(err.Error())
+	if err = c.prepare(); err != nil {
+		c.chunkLog.Println("[WARNING] connect failed:", err.Error())
 		return
 	}
 	// Connectoin Completed
 
+	if limits.CommandTimeout > 0 {
+		c.Conn.SetDeadline(time.Now().Add(time.Duration(limits.CommandTimeout) * time.Millisecond))
+	} else {
+		c.Conn.SetDeadline(time.Time{})
+	}
 	for c.Stage < commandStageDone {
-		c.readMessage()
+		// rejected publishers and players are closed in this stage
+		if err = c.readMessage(); err != nil {
+			c.chunkLog.Println("[DEBUG] closed before publish or play:", err.Error())
+			return
+		}
 	}
 	// CommandStage Completed
+	c.Conn.SetDeadline(time.Time{})
 
+	// a publisher which is gone or sends no media is closed
+	idle := time.Duration(limits.IdleTimeout) * time.Millisecond
+	c.mediaAt = time.Now()
 	for {
-		if err := c.readChunk(); err != nil {
+		if idle > 0 {
+			c.Conn.SetReadDeadline(c.mediaAt.Add(idle))
+		}
+		if err = c.readChunk(); err != nil {
 			c.closeConnection()
 			return
 		}
@@ -248,8 +422,19 @@ func (c *Connection) readChunk() error {
 	} else {
 		chunk.header.hasExtendedTimestamp = false
 	}
-	chunk.delta = chunk.header.timestamp
-	chunk.clock += chunk.header.timestamp
+	// fmt 0 carries the absolute timestamp and fmt 1 and 2 carry the delta
+	// fmt 3 repeats the previous delta when it starts a new message
+	if chunk.bytes == 0 {
+		switch _fmt {
+		case 0:
+			chunk.clock = chunk.header.timestamp
+		case 1, 2:
+			chunk.delta = chunk.header.timestamp
+			chunk.clock += chunk.delta
+		case 3:
+			chunk.clock += chunk.delta
+		}
+	}
 
 	// if we don't have payload
 	if chunk.bytes == 0 {
@@ -262,6 +447,10 @@ func (c *Connection) readChunk() error {
 	if size > c.ReadMaxChunkSize {
 		size = c.ReadMaxChunkSize
 	}
+	// the peer may set a chunk size bigger than our buffer
+	if bytesRead+size > len(c.ReadBuffer) {
+		c.ReadBuffer = append(c.ReadBuffer, make([]byte, bytesRead+size-len(c.ReadBuffer))...)
+	}
 
 	// read payload
 	n, err := io.ReadFull(c.Reader, c.ReadBuffer[bytesRead:bytesRead+size])
@@ -275,16 +464,6 @@ func (c *Connection) readChunk() error {
 	chunk.bytes += n
 	bytesRead += n
 
-	// if we finish handling the commands and we want to send it
-	// to the correspondings servers like twitch and...
-	if c.Stage == commandStageDone {
-		temp := make([]byte, bytesRead)
-		copy(temp, c.ReadBuffer[:bytesRead])
-		for _, client := range c.Clients {
-			client.Send <- temp
-		}
-	}
-
 	// if we got the whole chunk and we are ready to handle them
 	if chunk.bytes == int(chunk.header.length) {
 		c.GotMessage = true
@@ -315,17 +494,29 @@ func (c *Connection) handleChunk(chunk *rtmpChunk) {
 		c.handleAmf0Commad(chunk)
 
 	case 18:
+		c.bytesIn += int64(chunk.header.length)
 		c.handleDataMessage(chunk)
 
 	case 8:
+		c.bytesIn += int64(chunk.header.length)
+		c.mediaAt = time.Now()
+		if c.stats != nil {
+			c.stats.add(false, chunk.clock, chunk.payload)
+		}
 		c.handleAudioData(chunk)
+		c.reportCodec()
 
 	case 9:
+		c.bytesIn += int64(chunk.header.length)
+		c.mediaAt = time.Now()
+		if c.stats != nil {
+			c.stats.add(true, chunk.clock, chunk.payload)
+		}
 		c.handleVidoeData(chunk)
+		c.reportCodec()
 
 	default:
-		c.log.Println("[ERROR] error in handling chunk")
-		c.log.Println(chunk.header.messageType)
+		c.chunkLog.Printf("[DEBUG] ignored message type %d\n", chunk.header.messageType)
 	}
 }
 
@@ -333,6 +524,7 @@ func (c *Connection) handleChunk(chunk *rtmpChunk) {
 // For more info refer to wikipedia page in README.md
 func (c *Connection) handleAmf0Commad(chunk *rtmpChunk) {
 	command := amf.Decode(chunk.payload)
+	c.amfLog.Printf("[DEBUG] amf0 command %v\n", command["cmd"])
 
 	switch command["cmd"] {
 	case "connect":
@@ -354,12 +546,19 @@ func (c *Connection) handleAmf0Commad(chunk *rtmpChunk) {
 	case "receiveAudio":
 	case "receiveVideo":
 	default:
-		c.log.Println("[ERROR] UNKNOWN AMF0 COMMAND")
+		c.amfLog.Printf("[WARNING] unknown amf0 command %v\n", command["cmd"])
 	}
 }
 
 func (c *Connection) onConnect(command map[string]interface{}) {
 	c.AppName = command["cmdObj"].(map[string]interface{})["app"].(string)
+	// the stream name may be in the app until publish or play trims it
+	app := strings.SplitN(c.AppName, "/", 2)[0]
+	c.fields.Set("app", app)
+	e := c.event(events.Connect)
+	e.App = app
+	e.IP = c.remoteIP()
+	c.Events.Publish(e)
 
 	// TODO fix these methods
 	c.setMaxWriteChunkSize(128)
@@ -472,66 +671,134 @@ func (c *Connection) onPublish(command map[string]interface{}, messageStreamID u
 		payload:  amfPayload,
 	}
 
-	key := command["streamName"].(string)
-	c.Context.set(key, c)
+	name := command["streamName"].(string)
+	// some clients put the stream name in the app of connect too
+	c.AppName = strings.TrimSuffix(c.AppName, "/"+name)
+	key, query := splitStreamName(name)
+	// the backends know the key and get the backup role with it
+	backup := false
+	if settings.FailoverSettings().Items.Enabled {
+		key, backup = failoverKey(key)
+	}
 	c.StreamKey = key
-	response, err := c.RPC.Get(context.Background(), &protos.UsersInfoRequest{
-		Key: key,
+	c.SessionID = newSessionID()
+	c.fields.Set("app", c.AppName)
+	c.fields.Set("key", logging.Redact(key))
+	c.fields.Set("session", c.SessionID)
+	if !acl.Allowed(acl.Publish, c.AppName, c.remoteIP()) {
+		c.log.Printf("[WARNING] %s isn't allowed to publish to %s\n", c.remoteIP(), c.AppName)
+		c.closed = "ip not allowed"
+		c.Conn.Close()
+		return
+	}
+	response, err := c.Auth.Authenticate(context.Background(), auth.Request{
+		Action: auth.ActionPublish,
+		App:    c.AppName,
+		Key:    key,
+		Query:  query,
+		IP:     c.remoteIP(),
+		Backup: backup,
 	})
 	if err != nil {
 		c.log.Println(err.Error())
+		c.closed = "authorization failed"
 		c.Conn.Close()
 		return
 	}
 	// if user is not authorized
-	if !response.Auth {
+	if !response.Allowed {
+		c.closed = "not allowed"
 		c.Conn.Close()
 		return
 	}
-	jsonDatas, err := os.ReadFile("services/servers.json")
-	if err != nil {
-		c.log.Println(err.Error())
+	if c.Context.isClosing() {
+		c.closed = "shutdown"
+		c.Conn.Close()
 		return
 	}
-	jsonMap := Services{}
-	json.Unmarshal(jsonDatas, &jsonMap)
-	fmt.Printf("%v\n", jsonMap)
-
-	userChannel := []UserChannel{}
-
-	if response.Twitch.GetKey() != "" {
-		ch := UserChannel{
-			Name: jsonMap.Services[0].Servers[0].Name,
-			URL:  jsonMap.Services[0].Servers[0].URL,
-			Key:  response.Twitch.GetKey(),
+	if !acquire(&c.Context.publishers, settings.LimitsSettings().Items.MaxPublishers) {
+		metrics.Add("rejected.publishers", 1)
+		c.log.Println("[WARNING] rejected the publisher, over the publishers limit")
+		c.closed = "too many publishers"
+		c.Conn.Close()
+		return
+	}
+	c.slot = &c.Context.publishers
+	atomic.StoreInt32(&c.role, rolePublisher)
+	if settings.FailoverSettings().Items.Enabled {
+		c.backup = backup || response.Backup
+	}
+	c.publishStart = time.Now()
+	c.stats = &stats{}
+	c.Events.Publish(c.event(events.PublishStart))
+	if response.Record || shouldRecord(c.AppName) {
+		c.startRecording()
+	}
+	if settings.FailoverSettings().Items.Enabled {
+		// the standby gets the destinations of the active publisher
+		// when it takes over so it doesn't connect to them
+		if c.Context.joinFailover(c) {
+			for _, ch := range c.create(chunk) {
+				c.Writer.Write(ch)
+			}
+			c.Writer.Flush()
+			c.Stage++
+			return
 		}
-		userChannel = append(userChannel, ch)
 	}
-	if response.Youtube.GetKey() != "" {
-		ch := UserChannel{
-			Name: jsonMap.Services[1].Servers[0].Name,
-			URL:  jsonMap.Services[1].Servers[0].URL,
-			Key:  response.Twitch.GetKey(),
+	c.Context.set(key, c)
+	c.PlayName = response.Play
+	if c.PlayName == "" {
+		c.PlayName = auth.PlayName(key)
+	}
+	c.Context.setPlay(c.PlayName, key)
+	c.log.Printf("stream published to %s can be played as %s\n", c.AppName, c.PlayName)
+	// the destinations are still connected and get the slate
+	// until the first keyframe of this publisher
+	if fb := c.Context.takeFallback(key); fb != nil {
+		c.resume = fb
+		for _, ch := range c.create(chunk) {
+			c.Writer.Write(ch)
 		}
-		userChannel = append(userChannel, ch)
+		c.Writer.Flush()
+		c.Stage++
+		return
 	}
-	if response.Aparat.GetKey() != "" {
-		ch := UserChannel{
-			Name: jsonMap.Services[2].Servers[0].Name,
-			URL:  jsonMap.Services[2].Servers[0].URL,
-			Key:  response.Twitch.GetKey(),
+	services := c.Context.catalog()
+	userChannel := []UserChannel{}
+	for _, service := range []struct{ name, key string }{
+		{"Twitch", response.Twitch},
+		{"Youtube", response.Youtube},
+		{"Aparat", response.Aparat},
+	} {
+		if service.key == "" {
+			continue
+		}
+		server, ok := services.server(service.name)
+		if !ok {
+			c.log.Printf("[WARNING] %s is not in the services catalog\n", service.name)
+			continue
 		}
-		userChannel = append(userChannel, ch)
+		userChannel = append(userChannel, UserChannel{
+			Name: server.Name,
+			URL:  server.URL,
+			Key:  service.key,
+		})
+	}
+	// destinations have the key in their url already
+	for _, destination := range response.Destinations {
+		userChannel = append(userChannel, UserChannel{
+			Name: destination.Name,
+			URL:  destination.URL,
+		})
 	}
 
 	for _, channel := range userChannel {
-		ch := Channel{
-			ChannelName: channel.Name,
-			Send:        make(chan []byte, 100),
-			Exit:        make(chan bool, 5),
+		url := channel.URL
+		if channel.Key != "" {
+			url += "/" + channel.Key
 		}
-		c.Clients = append(c.Clients, ch)
-		c.prepareClient(channel.URL+"/"+channel.Key, ch)
+		c.addDestination(channel.Name, url)
 	}
 
 	for _, ch := range c.create(chunk) {
@@ -543,51 +810,272 @@ func (c *Connection) onPublish(command map[string]interface{}, messageStreamID u
 }
 
 func (c *Connection) closeConnection() {
-	for _, client := range c.Clients {
-		client.Exit <- true
+	unlock := c.lockClients()
+	c.ended = true
+	unlock()
+	c.stopRecording()
+	if !c.publishStart.IsZero() {
+		e := c.event(events.PublishStop)
+		e.Error = c.stopped
+		e.Bytes = c.bytesIn
+		e.Duration = time.Since(c.publishStart).Milliseconds()
+		e.Codec = c.codecInfo()
+		c.Events.Publish(e)
+		c.log.Printf("publish ended after %s: %s\n", time.Since(c.publishStart).Round(time.Second), c.stats.get())
+	}
+	if c.StreamKey != "" {
+		c.Context.delete(c.StreamKey, c)
+	}
+	if c.failover != nil && c.Context.leaveFailover(c) {
+		return
+	}
+	defer c.lockClients()()
+	// the players waiting for a keyframe end or get the slate with the others
+	c.Clients = append(c.Clients, c.WaitingClient...)
+	c.WaitingClient = nil
+	// dropped again before the slate was replaced
+	if c.resume != nil {
+		if c.stopped != "" {
+			c.resume.stop()
+			c.resume.exit()
+		} else {
+			c.Context.restoreFallback(c.StreamKey, c.resume)
+		}
+		c.resume = nil
+	}
+	if settings.FallbackSettings().Items.GracePeriod > 0 && c.stopped == "" && c.StreamKey != "" && len(c.Clients) > 0 {
+		c.Context.startFallback(c)
+		return
+	}
+	c.endClients(c.Clients, c.streamID)
+}
+
+// shouldRecord reports whether the app is listed in the record section
+func shouldRecord(app string) bool {
+	if !settings.RecordSettings().Items.Enabled {
+		return false
+	}
+	for _, a := range strings.Split(settings.RecordSettings().Items.Apps, ",") {
+		a = strings.TrimSpace(a)
+		if a == "*" || a == app {
+			return true
+		}
+	}
+	return false
+}
+
+func (c *Connection) startRecording() {
+	items := settings.RecordSettings().Items
+	opts := record.Options{
+		Dir:         items.Path,
+		FileName:    items.FileName,
+		App:         c.AppName,
+		Key:         c.StreamKey,
+		Session:     c.SessionID,
+		MaxSize:     items.MaxSize * 1024 * 1024,
+		MaxDuration: time.Duration(items.MaxDuration) * time.Second,
+		Finished: func(path string, duration uint32, size int64) {
+			e := c.event(events.RecordFinished)
+			e.File = path
+			e.Duration = int64(duration)
+			e.Bytes = size
+			c.Events.Publish(e)
+		},
+	}
+	if opts.Dir == "" {
+		opts.Dir = "recordings"
+	}
+	opts.FileName = strings.TrimSuffix(opts.FileName, filepath.Ext(opts.FileName))
+
+	formats := items.Format
+	if formats == "" {
+		formats = "flv"
+	}
+	for _, format := range strings.Split(formats, ",") {
+		switch strings.TrimSpace(format) {
+		case "flv":
+			c.recorders = append(c.recorders, record.NewFLVRecorder(c.log, opts))
+		case "mp4":
+			c.recorders = append(c.recorders, record.NewMP4Recorder(c.log, opts, items.FastStart))
+		default:
+			c.log.Println("[ERROR] unknown record format", format)
+		}
 	}
 }
 
+// record passes the message to every recorder of the connection
+// a recorder which fails is closed and won't be used anymore
+func (c *Connection) record(chunk *rtmpChunk) {
+	for i := 0; i < len(c.recorders); i++ {
+		err := c.recorders[i].Write(chunk.header.messageType, chunk.clock, chunk.payload)
+		if err == nil {
+			continue
+		}
+		// the errors of the files have the key in their path
+		c.log.Println("[ERROR] recording stopped:", c.redact(err.Error()))
+		c.recorders[i].Close()
+		c.recorders = append(c.recorders[:i], c.recorders[i+1:]...)
+		i--
+	}
+}
+
+// redact replaces the stream key in s with its hash
+func (c *Connection) redact(s string) string {
+	if c.StreamKey == "" {
+		return s
+	}
+	return strings.ReplaceAll(s, c.StreamKey, logging.Redact(c.StreamKey))
+}
+
+func (c *Connection) stopRecording() {
+	for _, r := range c.recorders {
+		if err := r.Close(); err != nil {
+			c.log.Println("[ERROR] recording stopped:", c.redact(err.Error()))
+		}
+	}
+	c.recorders = nil
+}
+
 func (c *Connection) handleDataMessage(chunk *rtmpChunk) {
 	command := amf.Decode(chunk.payload)
 
 	switch command["cmd"] {
-	case "@setDataFrame":
+	case "@setDataFrame", "onMetaData":
+		unlock := c.lockClients()
 		c.MetaData = append(c.MetaData, chunk.payload...)
+		unlock()
+		c.record(chunk)
+		c.forward(chunk)
+	}
+}
+
+// forward sends the message to the correspondings servers like twitch and...
+// it is sent with a full header so it doesn't depend on the
+// chunk headers and chunk size of the publisher
+func (c *Connection) forward(chunk *rtmpChunk) {
+	defer c.lockClients()()
+	if c.Stage != commandStageDone {
+		return
+	}
+	if chunk.header.messageType == 8 || chunk.header.messageType == 9 {
+		c.lastMedia = time.Now()
+	}
+	if c.switching {
+		// the clients can only start decoding this publisher from a keyframe
+		if chunk.header.messageType != 9 || len(chunk.payload) == 0 || chunk.payload[0]>>4 != 1 {
+			return
+		}
+		c.switching = false
+		c.continueFrom(c.lastTimestamp, chunk)
+	}
+	if len(c.Clients) == 0 {
+		return
+	}
+	data := bytes.Join(c.create(c.outgoing(chunk)), nil)
+	// the players which are gone are removed
+	clients := c.Clients[:0]
+	for _, client := range c.Clients {
+		if client.send(data) {
+			clients = append(clients, client)
+		}
 	}
+	c.Clients = clients
+}
+
+// outgoing returns the message with a full header and the timestamp
+// which the clients expect
+func (c *Connection) outgoing(chunk *rtmpChunk) *rtmpChunk {
+	msg := chunk.withFullHeader()
+	msg.header.timestamp = uint32(int64(chunk.clock) + c.tsOffset)
+	if chunk.header.messageType == 8 || chunk.header.messageType == 9 {
+		c.lastTimestamp = msg.header.timestamp
+		c.streamID = msg.header.messageStreamID
+	}
+	return msg
 }
 
 func (c *Connection) handleAudioData(chunk *rtmpChunk) {
-	chunk.header.timestamp = chunk.clock
 	if !c.GotFirstAudio {
+		unlock := c.lockClients()
 		c.FirstAudio = append(c.FirstAudio, chunk.payload...)
+		unlock()
+		c.stats.setCodec(c.codecInfo())
 	}
 	c.GotFirstAudio = true
+	c.record(chunk)
+	c.forward(chunk)
 }
 
 func (c *Connection) handleVidoeData(chunk *rtmpChunk) {
-	chunk.header.timestamp = chunk.clock
 	if !c.GotFirstVideo {
+		unlock := c.lockClients()
 		c.FirstVideo = append(c.FirstVideo, chunk.payload...)
+		unlock()
+		c.stats.setCodec(c.codecInfo())
 	}
 	c.GotFirstVideo = true
-	if len(c.WaitingClient) > 0 {
+	c.record(chunk)
+	if c.resume != nil && len(chunk.payload) > 0 && chunk.payload[0]>>4 == 1 {
+		c.resumeClients(chunk)
+	}
+	c.forward(chunk)
+	defer c.lockClients()()
+	if len(c.WaitingClient) > 0 && len(chunk.payload) > 0 {
 		frameType := chunk.payload[0] >> 4
 		if frameType == 1 {
-			for i, client := range c.WaitingClient {
-				for _, ch := range c.create(chunk) {
-					client.Send <- ch
+			// all of them start from this keyframe
+			for _, client := range c.WaitingClient {
+				for _, ch := range c.create(c.outgoing(chunk)) {
+					client.send(ch)
 				}
 				c.Clients = append(c.Clients, client)
-				c.WaitingClient = append(c.WaitingClient[:i], c.WaitingClient[i+1:]...)
 			}
+			c.WaitingClient = nil
 		}
 	}
 }
 
+// onPlay sends the stream of the public play name to the player
+// players never use the stream key which is the secret of the publisher
 func (c *Connection) onPlay(command map[string]interface{}, playChunk *rtmpChunk) {
-	co := c.Context.get(command["streamName"].(string))
+	if c.Context.isClosing() {
+		c.playFailed(playChunk, "NetStream.Play.Failed", "Server is shutting down")
+		return
+	}
+	name, query := splitStreamName(command["streamName"].(string))
+	c.fields.Set("key", logging.Redact(name))
+	if !acl.Allowed(acl.Play, c.AppName, c.remoteIP()) {
+		c.playFailed(playChunk, "NetStream.Play.Failed", "Not allowed to play from "+c.remoteIP())
+		return
+	}
+	response, err := c.Auth.Authenticate(context.Background(), auth.Request{
+		Action: auth.ActionPlay,
+		App:    c.AppName,
+		Key:    name,
+		Query:  query,
+		IP:     c.remoteIP(),
+	})
+	if err != nil {
+		c.log.Println(err.Error())
+		c.playFailed(playChunk, "NetStream.Play.Failed", "Authorization failed")
+		return
+	}
+	if !response.Allowed {
+		c.playFailed(playChunk, "NetStream.Play.Failed", "Not allowed to play "+name)
+		return
+	}
+	if !acquire(&c.Context.players, settings.LimitsSettings().Items.MaxPlayers) {
+		metrics.Add("rejected.players", 1)
+		c.playFailed(playChunk, "NetStream.Play.Failed", "Too many players")
+		return
+	}
+	c.slot = &c.Context.players
+	var co *Connection
+	if key := c.Context.playKey(name); key != "" {
+		co = c.Context.get(key)
+	}
 	if co == nil {
+		c.playFailed(playChunk, "NetStream.Play.StreamNotFound", "No stream named "+name)
 		return
 	}
 	b := make([]byte, 6)
@@ -660,6 +1148,13 @@ func (c *Connection) onPlay(command map[string]interface{}, playChunk *rtmpChunk
 
 	c.Writer.Flush()
 	c.Stage = commandStageDone
+	// players don't get back to Handle until they're done
+	c.Conn.SetDeadline(time.Time{})
+
+	// the publisher writes its headers under lockClients
+	unlock := co.lockClients()
+	metaData, firstAudio, firstVideo := co.MetaData, co.FirstAudio, co.FirstVideo
+	unlock()
 
 	chunk = &rtmpChunk{
 		header: &header{
@@ -668,13 +1163,13 @@ func (c *Connection) onPlay(command map[string]interface{}, playChunk *rtmpChunk
 			messageType:     18,
 			messageStreamID: playChunk.header.messageStreamID,
 			timestamp:       0,
-			length:          uint32(len(co.MetaData)),
+			length:          uint32(len(metaData)),
 		},
 		clock:    0,
 		delta:    0,
 		capacity: 0,
 		bytes:    0,
-		payload:  co.MetaData,
+		payload:  metaData,
 	}
 	for _, ch := range c.create(chunk) {
 		c.Writer.Write(ch)
@@ -689,13 +1184,13 @@ func (c *Connection) onPlay(command map[string]interface{}, playChunk *rtmpChunk
 			messageType:     8,
 			messageStreamID: playChunk.header.messageStreamID,
 			timestamp:       0,
-			length:          uint32(len(co.FirstAudio)),
+			length:          uint32(len(firstAudio)),
 		},
 		clock:    0,
 		delta:    0,
 		capacity: 0,
 		bytes:    0,
-		payload:  co.FirstAudio,
+		payload:  firstAudio,
 	}
 	for _, ch := range c.create(chunk) {
 		c.Writer.Write(ch)
@@ -710,13 +1205,13 @@ func (c *Connection) onPlay(command map[string]interface{}, playChunk *rtmpChunk
 			messageType:     9,
 			messageStreamID: playChunk.header.messageStreamID,
 			timestamp:       0,
-			length:          uint32(len(co.FirstVideo)),
+			length:          uint32(len(firstVideo)),
 		},
 		clock:    0,
 		delta:    0,
 		capacity: 0,
 		bytes:    0,
-		payload:  co.FirstVideo,
+		payload:  firstVideo,
 	}
 	for _, ch := range c.create(chunk) {
 		c.Writer.Write(ch)
@@ -725,23 +1220,121 @@ func (c *Connection) onPlay(command map[string]interface{}, playChunk *rtmpChunk
 	// Video Data Sent
 
 	ch := Channel{
-		ChannelName: "-1",
+		ChannelName: playerChannel,
 		Send:        make(chan []byte, 100),
 		Exit:        make(chan bool, 5),
+		Done:        make(chan struct{}),
+	}
+	unlock = co.lockClients()
+	// the publisher closed meanwhile and won't end a new client
+	if co.ended {
+		unlock()
+		c.Writer.Write(co.unpublishNotify(playChunk.header.messageStreamID))
+		c.Writer.Flush()
+		c.closed = "stream ended"
+		c.Conn.Close()
+		return
 	}
 	co.WaitingClient = append(co.WaitingClient, ch)
+	unlock()
+	atomic.StoreInt32(&c.role, rolePlayer)
+	e := co.event(events.PlayStart)
+	e.IP = c.remoteIP()
+	c.Events.Publish(e)
 
 	func(client *Connection) {
 		clientWriter := bufio.NewWriter(c.Conn)
+		// a player which doesn't take its messages in time is gone
+		timeout := time.Duration(settings.LimitsSettings().Items.WriteTimeout) * time.Millisecond
+		deadline := func() {
+			if timeout > 0 {
+				client.Conn.SetWriteDeadline(time.Now().Add(timeout))
+			}
+		}
 		for {
+			var err error
 			select {
 			case chunk := <-ch.Send:
-				clientWriter.Write(chunk)
+				deadline()
+				if _, err = clientWriter.Write(chunk); err == nil {
+					err = clientWriter.Flush()
+				}
 			case <-ch.Exit:
+				c.closed = "stream ended"
+				deadline()
+				// messages sent before the exit like the unpublish notify go out first
+				for pending := true; pending; {
+					select {
+					case chunk := <-ch.Send:
+						clientWriter.Write(chunk)
+					default:
+						pending = false
+					}
+				}
+				clientWriter.Flush()
+				client.Conn.Close()
+				return
+			}
+			if err != nil {
+				close(ch.Done)
+				c.closed = "write failed"
+				if errors.Is(err, os.ErrDeadlineExceeded) {
+					c.closed = "write timeout"
+				}
+				c.log.Println("[WARNING] player is gone:", err.Error())
 				client.Conn.Close()
 				return
 			}
-			clientWriter.Flush()
 		}
 	}(c)
 }
+
+// playFailed tells the player why it can't play and closes the connection
+func (c *Connection) playFailed(playChunk *rtmpChunk, code, description string) {
+	info := flvio.AMFMap{
+		"level":       "error",
+		"code":        code,
+		"description": description,
+	}
+	amfPayload, _ := amf.Encode("onStatus", 0, nil, info)
+
+	chunk := &rtmpChunk{
+		header: &header{
+			fmt:             0,
+			csid:            3,
+			messageType:     20,
+			messageStreamID: playChunk.header.messageStreamID,
+			timestamp:       0,
+			length:          uint32(len(amfPayload)),
+		},
+		payload: amfPayload,
+	}
+	for _, ch := range c.create(chunk) {
+		c.Writer.Write(ch)
+	}
+	c.Writer.Flush()
+	c.log.Printf("play from %s failed with %s: %s\n", c.remoteIP(), code, description)
+	c.closed = code
+	c.Conn.Close()
+}
+
+// event returns an event of the publish session of c
+func (c *Connection) event(t events.Type) events.Event {
+	return events.Event{
+		Type:    t,
+		App:     c.AppName,
+		Key:     c.StreamKey,
+		Session: c.SessionID,
+	}
+}
+
+// reportCodec publishes the codecs once both the audio and video headers are received
+func (c *Connection) reportCodec() {
+	if c.codecReported || !c.GotFirstAudio || !c.GotFirstVideo {
+		return
+	}
+	c.codecReported = true
+	e := c.event(events.PublishCodec)
+	e.Codec = c.codecInfo()
+	c.Events.Publish(e)
+}
@@ -0,0 +1,192 @@
+package rtmp
+
+import (
+	"errors"
+	"time"
+
+	"github.com/alipourhabibi/restream/events"
+	"github.com/alipourhabibi/restream/logging"
+)
+
+// Errors of the control methods of Stream
+var (
+	ErrStreamNotFound      = errors.New("stream not found")
+	ErrDestinationNotFound = errors.New("destination not found")
+	ErrDestinationExists   = errors.New("destination already exists")
+)
+
+// StreamInfo describes a live stream
+// Key is the credential of the publisher, it's only for the control api
+// and KeyHash is the key hashed like in the logs
+type StreamInfo struct {
+	Key          string    `json:"-"`
+	KeyHash      string    `json:"keyHash"`
+	App          string    `json:"app"`
+	Session      string    `json:"session"`
+	Play         string    `json:"play"`
+	Started      time.Time `json:"started"`
+	Destinations []string  `json:"destinations"`
+	Players      int       `json:"players"`
+	Stats        Stats     `json:"stats"`
+	// nil before the first audio or video message
+	Codec *events.Codec `json:"codec"`
+}
+
+// lockClients guards the clients of c while they are used by other goroutines
+// a connection with failover uses the lock of its failover
+// as its clients can be moved to the other publisher
+func (c *Connection) lockClients() func() {
+	if c.failover != nil {
+		c.failover.mu.Lock()
+		return c.failover.mu.Unlock
+	}
+	if c.clientsMu == nil {
+		return func() {}
+	}
+	c.clientsMu.Lock()
+	return c.clientsMu.Unlock
+}
+
+// addDestination starts sending the stream to a server
+func (c *Connection) addDestination(name, url string) error {
+	defer c.lockClients()()
+	for _, client := range c.Clients {
+		if client.ChannelName == name {
+			return ErrDestinationExists
+		}
+	}
+	ch := Channel{
+		ChannelName: name,
+		Send:        make(chan []byte, 100),
+		Exit:        make(chan bool, 5),
+		dropped:     new(int32),
+	}
+	c.Clients = append(c.Clients, ch)
+	// a destination added during the stream starts from the headers
+	// and the next keyframe, there are none before the first media
+	// the publisher writes them under lockClients too
+	c.prepareClient(name, url, ch, c.headerMessages(c.lastTimestamp, c.streamID))
+	return nil
+}
+
+// removeDestination stops sending the stream to a server
+func (c *Connection) removeDestination(name string) error {
+	defer c.lockClients()()
+	for i, client := range c.Clients {
+		if client.ChannelName == name {
+			c.Clients = append(c.Clients[:i:i], c.Clients[i+1:]...)
+			client.exit()
+			return nil
+		}
+	}
+	return ErrDestinationNotFound
+}
+
+// Streams returns the live streams
+func (s *Stream) Streams() []StreamInfo {
+	s.ctx.mu.Lock()
+	conns := make([]*Connection, 0, len(s.ctx.sessions))
+	for _, c := range s.ctx.sessions {
+		conns = append(conns, c)
+	}
+	s.ctx.mu.Unlock()
+
+	streams := make([]StreamInfo, 0, len(conns))
+	for _, c := range conns {
+		info := StreamInfo{
+			Key:     c.StreamKey,
+			KeyHash: logging.Redact(c.StreamKey),
+			App:     c.AppName,
+			Session: c.SessionID,
+			Play:    c.PlayName,
+			Started: c.publishStart,
+			Stats:   c.stats.get(),
+			Codec:   c.stats.getCodec(),
+		}
+		unlock := c.lockClients()
+		for _, client := range c.Clients {
+			// players are added with the name -1
+			if client.ChannelName == playerChannel {
+				info.Players++
+			} else {
+				info.Destinations = append(info.Destinations, client.ChannelName)
+			}
+		}
+		info.Players += len(c.WaitingClient)
+		unlock()
+		streams = append(streams, info)
+	}
+	return streams
+}
+
+// Kick disconnects the publishers of key and ends its destinations and players
+// without the fallback slate or switching to the backup publisher
+func (s *Stream) Kick(key, reason string) error {
+	if !s.end(key, "kicked", reason) {
+		return ErrStreamNotFound
+	}
+	return nil
+}
+
+// end stops the fallback of key and disconnects its publishers which are
+// marked as stopped for why, it reports whether the key had any of them
+func (s *Stream) end(key, why, reason string) bool {
+	found := false
+	if fb := s.ctx.takeFallback(key); fb != nil {
+		fb.stop()
+		fb.exit()
+		found = true
+	}
+	c := s.ctx.get(key)
+	if c == nil {
+		return found
+	}
+
+	unlock := c.lockClients()
+	c.stopped = why
+	var standby *Connection
+	if c.failover != nil {
+		standby = c.failover.standby
+		if standby != nil {
+			standby.stopped = why
+		}
+	}
+	unlock()
+
+	c.log.Printf("%s the publishers of %s: %s\n", why, c.AppName, reason)
+	if standby != nil {
+		standby.Conn.Close()
+	}
+	c.Conn.Close()
+	return true
+}
+
+// AddDestination starts sending the stream of key to the rtmp url
+func (s *Stream) AddDestination(key, name, url string) error {
+	c := s.ctx.get(key)
+	if c == nil {
+		return ErrStreamNotFound
+	}
+	return c.addDestination(name, url)
+}
+
+// RemoveDestination stops sending the stream of key to the destination
+func (s *Stream) RemoveDestination(key, name string) error {
+	c := s.ctx.get(key)
+	if c == nil {
+		return ErrStreamNotFound
+	}
+	return c.removeDestination(name)
+}
+
+// UpdateDestination reconnects the destination with a new url
+func (s *Stream) UpdateDestination(key, name, url string) error {
+	c := s.ctx.get(key)
+	if c == nil {
+		return ErrStreamNotFound
+	}
+	if err := c.removeDestination(name); err != nil {
+		return err
+	}
+	return c.addDestination(name, url)
+}
@@ -0,0 +1,354 @@
+package rtmp
+
+import (
+	"bufio"
+	"log"
+	"net/url"
+	"path"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/alipourhabibi/restream/events"
+	"github.com/alipourhabibi/restream/logging"
+	"github.com/alipourhabibi/restream/settings"
+	"github.com/praveen001/joy4/format/rtmp"
+)
+
+// times a broken destination is dialed again before giving up
+const reconnectAttempts = 5
+
+// destination sends the messages of a Channel to a server like twitch
+// and reconnects to it when the connection breaks
+type destination struct {
+	log    *log.Logger
+	events *events.Dispatcher
+	base   events.Event
+	url    string
+	key    string
+	ch     Channel
+
+	conn   *rtmp.Conn
+	writer *bufio.Writer
+	// last metadata and sequence headers which are sent again after reconnecting
+	headers map[uint8][]byte
+	bytes   int64
+	start   time.Time
+	exited  bool
+	// FCUnpublish and deleteStream which are sent before closing
+	unpublish []byte
+	// counts the running destinations of the StreamContext
+	running *int64
+	// of dialing and of each write, a stuck server is reconnected
+	timeout time.Duration
+	// of the service of url, the stats of the publisher of the key
+	// in ctx are checked against them
+	ctx          *StreamContext
+	requirements *Requirements
+	violations   map[string]bool
+}
+
+// prepareClient connects to the destination in the background and sends it
+// the headers and then the messages of ch until ch.Exit
+func (c *Connection) prepareClient(name, url string, ch Channel, headers [][]byte) {
+	base := c.event("")
+	base.Destination = name
+	d := &destination{
+		log:          c.log,
+		events:       c.Events,
+		base:         base,
+		url:          url,
+		key:          urlKey(url),
+		ch:           ch,
+		headers:      make(map[uint8][]byte),
+		unpublish:    c.unpublish(url),
+		running:      &c.Context.destinations,
+		timeout:      time.Duration(settings.LimitsSettings().Items.WriteTimeout) * time.Millisecond,
+		ctx:          c.Context,
+		requirements: c.Context.catalog().requirements(url),
+	}
+	for _, msg := range headers {
+		d.remember(msg)
+	}
+	atomic.AddInt64(d.running, 1)
+	go d.run()
+}
+
+func (d *destination) run() {
+	defer atomic.AddInt64(d.running, -1)
+	d.start = time.Now()
+	defer d.publish(events.DestinationStop, nil)
+	if err := d.connect(); err != nil {
+		d.log.Printf("[ERROR] destination %s failed: %s\n", d.base.Destination, d.redact(err.Error()))
+		d.publish(events.DestinationFailed, err)
+		d.drain()
+		return
+	}
+	d.publish(events.DestinationConnected, nil)
+
+	waitKeyFrame := false
+	if len(d.headers) > 0 {
+		if err := d.writeHeaders(); err != nil {
+			if !d.reconnect(err) {
+				return
+			}
+		}
+		waitKeyFrame = true
+	}
+	check, stop := d.requirementTicker()
+	defer stop()
+	for {
+		select {
+		case <-check:
+			d.checkRequirements()
+		case msg := <-d.ch.Send:
+			d.remember(msg)
+			if atomic.CompareAndSwapInt32(d.ch.dropped, 1, 0) && !waitKeyFrame {
+				d.log.Printf("[WARNING] destination %s is behind, skipping to the next keyframe\n", d.base.Destination)
+				waitKeyFrame = true
+			}
+			// the server can only decode from a keyframe after reconnecting
+			if waitKeyFrame {
+				if !isKeyFrameMessage(msg) {
+					continue
+				}
+				waitKeyFrame = false
+			}
+			if err := d.write(msg); err != nil {
+				if !d.reconnect(err) {
+					return
+				}
+				waitKeyFrame = true
+			}
+		case <-d.ch.Exit:
+			d.close(waitKeyFrame)
+			return
+		}
+	}
+}
+
+// close sends the messages which are still queued and unpublishes the stream
+// so the server ends it right away instead of waiting for a timeout
+func (d *destination) close(waitKeyFrame bool) {
+	defer d.conn.Close()
+	for pending := true; pending; {
+		select {
+		case msg := <-d.ch.Send:
+			if waitKeyFrame {
+				continue
+			}
+			if err := d.write(msg); err != nil {
+				return
+			}
+		default:
+			pending = false
+		}
+	}
+	d.conn.WriteTrailer()
+	d.write(d.unpublish)
+}
+
+// connect dials the destination while its messages are dropped
+// so the publisher gets the buffer back during a slow handshake
+func (d *destination) connect() error {
+	result := make(chan error, 1)
+	go func() {
+		result <- d.dial()
+	}()
+	for {
+		select {
+		case msg := <-d.ch.Send:
+			d.remember(msg)
+		case err := <-result:
+			return err
+		}
+	}
+}
+
+func (d *destination) dial() error {
+	var conn *rtmp.Conn
+	var err error
+	if d.timeout > 0 {
+		conn, err = rtmp.DialTimeout(d.url, d.timeout)
+	} else {
+		conn, err = rtmp.Dial(d.url)
+	}
+	if err != nil {
+		return err
+	}
+	keepAlive(conn.NetConn())
+	d.deadline(conn)
+	if err := conn.Prepare(); err != nil {
+		conn.Close()
+		return err
+	}
+	d.conn = conn
+	d.writer = bufio.NewWriter(conn.NetConn())
+	return nil
+}
+
+// deadline sets the deadline of conn for its handshake or the next write
+func (d *destination) deadline(conn *rtmp.Conn) {
+	if d.timeout > 0 {
+		conn.NetConn().SetDeadline(time.Now().Add(d.timeout))
+	}
+}
+
+func (d *destination) write(msg []byte) error {
+	d.deadline(d.conn)
+	if _, err := d.writer.Write(msg); err != nil {
+		return err
+	}
+	d.bytes += int64(len(msg))
+	return d.writer.Flush()
+}
+
+func (d *destination) writeHeaders() error {
+	for _, msgType := range []uint8{18, 9, 8} {
+		if msg := d.headers[msgType]; msg != nil {
+			if err := d.write(msg); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// reconnect dials the destination again with backoff and sends it the headers
+// the messages sent meanwhile are dropped, it reports whether it reconnected
+func (d *destination) reconnect(cause error) bool {
+	d.conn.Close()
+	backoff := time.Second
+	for attempt := 1; attempt <= reconnectAttempts; attempt++ {
+		d.log.Printf("[WARNING] destination %s broke, reconnecting in %s: %s\n", d.base.Destination, backoff, d.redact(cause.Error()))
+		d.publish(events.DestinationReconnecting, cause)
+		if !d.wait(backoff) {
+			return false
+		}
+		backoff *= 2
+		if cause = d.connect(); cause != nil {
+			continue
+		}
+		d.publish(events.DestinationConnected, nil)
+		if cause = d.writeHeaders(); cause == nil {
+			return true
+		}
+		d.conn.Close()
+	}
+	d.publish(events.DestinationFailed, cause)
+	d.drain()
+	return false
+}
+
+// wait drops the messages for d and reports false if ch.Exit came meanwhile
+func (d *destination) wait(t time.Duration) bool {
+	timer := time.NewTimer(t)
+	defer timer.Stop()
+	for {
+		select {
+		case msg := <-d.ch.Send:
+			d.remember(msg)
+		case <-d.ch.Exit:
+			d.exited = true
+			return false
+		case <-timer.C:
+			return true
+		}
+	}
+}
+
+// drain drops the messages so the publisher is never blocked by this destination
+func (d *destination) drain() {
+	if d.exited {
+		return
+	}
+	for {
+		select {
+		case <-d.ch.Send:
+		case <-d.ch.Exit:
+			d.exited = true
+			return
+		}
+	}
+}
+
+// remember keeps the metadata and sequence headers going to the destination
+func (d *destination) remember(msg []byte) {
+	msgType, payload := messageInfo(msg)
+	switch {
+	case msgType == 18:
+	case msgType == 8 && len(payload) > 1 && payload[0]>>4 == 10 && payload[1] == 0:
+	case msgType == 9 && len(payload) > 1 && payload[0]&0x0f == 7 && payload[1] == 0:
+	default:
+		return
+	}
+	d.headers[msgType] = msg
+}
+
+func (d *destination) publish(t events.Type, err error) {
+	e := d.base
+	e.Type = t
+	if err != nil {
+		e.Error = d.redact(err.Error())
+	}
+	if t == events.DestinationStop {
+		e.Bytes = d.bytes
+		e.Duration = time.Since(d.start).Milliseconds()
+	}
+	d.events.Publish(e)
+}
+
+// redact replaces the stream key of the destination in s with its hash
+// the errors of dialing it can have its url
+func (d *destination) redact(s string) string {
+	if d.key == "" {
+		return s
+	}
+	return strings.ReplaceAll(s, d.key, logging.Redact(d.key))
+}
+
+// urlKey returns the last segment of the path of a destination url
+// which is its stream key
+func urlKey(rawURL string) string {
+	if u, err := url.Parse(rawURL); err == nil {
+		rawURL = u.Path
+	}
+	key := path.Base(rawURL)
+	if key == "." || key == "/" {
+		return ""
+	}
+	return key
+}
+
+// messageInfo returns the type and the start of the payload of a message
+// which starts with a fmt 0 chunk like the ones made by create
+func messageInfo(msg []byte) (uint8, []byte) {
+	if len(msg) == 0 {
+		return 0, nil
+	}
+	n := 1
+	switch msg[0] & 0x3f {
+	case 0:
+		n = 2
+	case 1:
+		n = 3
+	}
+	if len(msg) < n+11 {
+		return 0, nil
+	}
+	header := msg[n:]
+	payload := header[11:]
+	if header[0] == 0xff && header[1] == 0xff && header[2] == 0xff {
+		if len(payload) < 4 {
+			return 0, nil
+		}
+		payload = payload[4:]
+	}
+	return header[6], payload
+}
+
+// isKeyFrameMessage reports whether msg is a video keyframe
+func isKeyFrameMessage(msg []byte) bool {
+	msgType, payload := messageInfo(msg)
+	return msgType == 9 && len(payload) > 0 && payload[0]>>4 == 1
+}
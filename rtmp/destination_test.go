@@ -0,0 +1,77 @@
+package rtmp
+
+import (
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/alipourhabibi/restream/logging"
+)
+
+func TestChannelSend(t *testing.T) {
+	t.Run("destination drops while its buffer is full", func(t *testing.T) {
+		ch := Channel{Send: make(chan []byte, 1), Done: make(chan struct{}), dropped: new(int32)}
+		done := make(chan bool)
+		go func() {
+			done <- ch.send([]byte{1}) && ch.send([]byte{2})
+		}()
+		select {
+		case ok := <-done:
+			if !ok {
+				t.Fatal("send to a destination reported it gone")
+			}
+		case <-time.After(time.Second):
+			t.Fatal("send to a full destination blocked")
+		}
+		if atomic.LoadInt32(ch.dropped) != 1 {
+			t.Error("dropped isn't set")
+		}
+		if data := <-ch.Send; data[0] != 1 {
+			t.Errorf("kept message %d, want the first one", data[0])
+		}
+	})
+
+	t.Run("player blocks until it's gone", func(t *testing.T) {
+		ch := Channel{Send: make(chan []byte), Done: make(chan struct{})}
+		done := make(chan bool)
+		go func() {
+			done <- ch.send([]byte{1})
+		}()
+		select {
+		case <-done:
+			t.Fatal("send to a player didn't wait for it")
+		case <-time.After(50 * time.Millisecond):
+		}
+		close(ch.Done)
+		if <-done {
+			t.Error("send to a gone player reported it received")
+		}
+	})
+}
+
+func TestDestinationRedact(t *testing.T) {
+	tests := []struct {
+		url string
+		key string
+	}{
+		{"rtmp://live.twitch.tv/app/live_123_abc", "live_123_abc"},
+		{"rtmps://a.rtmp.youtube.com:443/live2/abcd-efgh?x=1", "abcd-efgh"},
+		{"rtmp://example.com/", ""},
+		{"rtmp://example.com", ""},
+	}
+	for _, tt := range tests {
+		if got := urlKey(tt.url); got != tt.key {
+			t.Errorf("urlKey(%s) = %q, want %q", tt.url, got, tt.key)
+			continue
+		}
+		d := &destination{url: tt.url, key: tt.key}
+		got := d.redact("dial " + tt.url + ": connection refused")
+		if tt.key != "" && strings.Contains(got, tt.key) {
+			t.Errorf("redact() = %s, has the key", got)
+		}
+		if tt.key != "" && !strings.Contains(got, logging.Redact(tt.key)) {
+			t.Errorf("redact() = %s, doesn't have the hash of the key", got)
+		}
+	}
+}
@@ -0,0 +1,183 @@
+package rtmp
+
+import (
+	"log"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/alipourhabibi/restream/logging"
+	"github.com/alipourhabibi/restream/settings"
+)
+
+// failover is a stream key with a primary and a backup publisher
+// only the active one is sent to the clients and the other one takes
+// its clients when it disconnects or stops sending media
+type failover struct {
+	mu      sync.Mutex
+	log     *log.Logger
+	key     string
+	active  *Connection
+	standby *Connection
+
+	switches   int
+	lastSwitch time.Time
+	reason     string
+
+	stop chan struct{}
+}
+
+// FailoverStatus is the state of a stream key with failover
+// Key is hashed like in the logs
+type FailoverStatus struct {
+	Key        string    `json:"key"`
+	Active     string    `json:"active"`
+	Standby    string    `json:"standby,omitempty"`
+	Switches   int       `json:"switches"`
+	LastSwitch time.Time `json:"lastSwitch"`
+	Reason     string    `json:"reason,omitempty"`
+}
+
+// failoverKey returns the stream key the publisher belongs to
+// and whether it named the backup of that key
+func failoverKey(key string) (string, bool) {
+	suffix := settings.FailoverSettings().Items.BackupSuffix
+	if suffix != "" && strings.HasSuffix(key, suffix) && key != suffix {
+		return strings.TrimSuffix(key, suffix), true
+	}
+	return key, false
+}
+
+func role(c *Connection) string {
+	if c == nil {
+		return ""
+	}
+	if c.backup {
+		return "backup"
+	}
+	return "primary"
+}
+
+// joinFailover adds c to the failover of its key and reports
+// whether it is waiting as the standby publisher
+func (ctx *StreamContext) joinFailover(c *Connection) bool {
+	ctx.mu.Lock()
+	g := ctx.failovers[c.StreamKey]
+	if g == nil {
+		g = &failover{
+			log:    c.log,
+			key:    c.StreamKey,
+			active: c,
+			stop:   make(chan struct{}),
+		}
+		ctx.failovers[c.StreamKey] = g
+		ctx.mu.Unlock()
+		c.failover = g
+		c.lastMedia = time.Now()
+		go g.watch(ctx)
+		return false
+	}
+	ctx.mu.Unlock()
+
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	c.failover = g
+	if g.standby != nil {
+		// the old standby is replaced by the newer publisher
+		c.log.Printf("failover of %s replaced its %s publisher\n", logging.Redact(g.key), role(g.standby))
+		g.standby.Conn.Close()
+	}
+	g.standby = c
+	c.log.Printf("failover of %s has a %s publisher waiting\n", logging.Redact(g.key), role(c))
+	return true
+}
+
+// leaveFailover removes c from its failover and reports whether
+// its clients were handed to the standby publisher
+func (ctx *StreamContext) leaveFailover(c *Connection) bool {
+	g := c.failover
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	switch c {
+	case g.standby:
+		g.standby = nil
+		return true
+	case g.active:
+		if g.standby != nil && c.stopped == "" {
+			g.switchOver(ctx, "disconnect")
+			g.standby = nil
+			return true
+		}
+		g.active = nil
+		ctx.mu.Lock()
+		if ctx.failovers[g.key] == g {
+			delete(ctx.failovers, g.key)
+		}
+		ctx.mu.Unlock()
+		close(g.stop)
+	}
+	return false
+}
+
+// switchOver moves the clients of the active publisher to the standby
+// which starts sending them from its next keyframe
+// it must be called with g.mu held
+func (g *failover) switchOver(ctx *StreamContext, reason string) {
+	from, to := g.active, g.standby
+	to.Clients = append(from.Clients, to.Clients...)
+	to.WaitingClient = append(from.WaitingClient, to.WaitingClient...)
+	from.Clients = nil
+	from.WaitingClient = nil
+	to.lastTimestamp = from.lastTimestamp
+	to.switching = true
+	// the standby returned from publish before it got a play name
+	to.PlayName = from.PlayName
+	g.active, g.standby = to, from
+
+	g.switches++
+	g.lastSwitch = time.Now()
+	g.reason = reason
+	g.log.Printf("failover of %s switched from %s to %s on %s\n", logging.Redact(g.key), role(from), role(to), reason)
+	ctx.set(g.key, to)
+	// the play name was removed with the session of the active publisher
+	// when it disconnected
+	if to.PlayName != "" {
+		ctx.setPlay(to.PlayName, g.key)
+	}
+}
+
+// watch switches to the standby publisher when the active one stalls
+func (g *failover) watch(ctx *StreamContext) {
+	timeout := time.Duration(settings.FailoverSettings().Items.StallTimeout) * time.Millisecond
+	if timeout <= 0 {
+		return
+	}
+	ticker := time.NewTicker(timeout / 4)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-g.stop:
+			return
+		case <-ticker.C:
+			g.mu.Lock()
+			if g.active != nil && g.standby != nil &&
+				time.Since(g.active.lastMedia) > timeout && time.Since(g.standby.lastMedia) < timeout {
+				g.switchOver(ctx, "stall")
+			}
+			g.mu.Unlock()
+		}
+	}
+}
+
+func (g *failover) status() FailoverStatus {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return FailoverStatus{
+		Key:        logging.Redact(g.key),
+		Active:     role(g.active),
+		Standby:    role(g.standby),
+		Switches:   g.switches,
+		LastSwitch: g.lastSwitch,
+		Reason:     g.reason,
+	}
+}
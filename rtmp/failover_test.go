@@ -0,0 +1,70 @@
+package rtmp
+
+import (
+	"io"
+	"log"
+	"testing"
+)
+
+func TestFailoverKey(t *testing.T) {
+	tests := []struct {
+		key    string
+		base   string
+		backup bool
+	}{
+		{"key", "key", false},
+		{"key_backup", "key", true},
+		{"_backup", "_backup", false},
+		{"key_backup_backup", "key_backup", true},
+		{"key_backupx", "key_backupx", false},
+	}
+	for _, tt := range tests {
+		if base, backup := failoverKey(tt.key); base != tt.base || backup != tt.backup {
+			t.Errorf("failoverKey(%s) = %s, %v, want %s, %v", tt.key, base, backup, tt.base, tt.backup)
+		}
+	}
+}
+
+func TestSwitchOver(t *testing.T) {
+	discard := log.New(io.Discard, "", 0)
+	ctx := &StreamContext{
+		sessions:  make(map[string]*Connection),
+		failovers: make(map[string]*failover),
+		plays:     make(map[string]string),
+	}
+	player := Channel{ChannelName: playerChannel}
+	waiting := Channel{ChannelName: playerChannel}
+	primary := &Connection{
+		log:           discard,
+		StreamKey:     "key",
+		PlayName:      "public",
+		Clients:       []Channel{player},
+		WaitingClient: []Channel{waiting},
+		lastTimestamp: 4000,
+	}
+	// the backup joined without a play name and the primary disconnected
+	// which removed its play name with its session
+	backup := &Connection{log: discard, StreamKey: "key", backup: true}
+	g := &failover{log: discard, key: "key", active: primary, standby: backup}
+
+	g.switchOver(ctx, "disconnect")
+
+	if g.active != backup || g.standby != primary {
+		t.Fatalf("active is the %s publisher", role(g.active))
+	}
+	if len(backup.Clients) != 1 || len(backup.WaitingClient) != 1 || primary.Clients != nil || primary.WaitingClient != nil {
+		t.Errorf("clients weren't moved to the backup")
+	}
+	if !backup.switching || backup.lastTimestamp != 4000 {
+		t.Errorf("backup continues from %d with switching %v", backup.lastTimestamp, backup.switching)
+	}
+	if ctx.get("key") != backup {
+		t.Errorf("the session of key isn't the backup")
+	}
+	if backup.PlayName != "public" || ctx.playKey("public") != "key" {
+		t.Errorf("play name %q plays %q", backup.PlayName, ctx.playKey("public"))
+	}
+	if s := g.status(); s.Key == "key" || s.Switches != 1 || s.Reason != "disconnect" || s.Active != "backup" {
+		t.Errorf("status = %+v", s)
+	}
+}
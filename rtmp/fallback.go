@@ -0,0 +1,284 @@
+package rtmp
+
+import (
+	"bytes"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/alipourhabibi/restream/logging"
+	"github.com/alipourhabibi/restream/settings"
+	"github.com/alipourhabibi/restream/source"
+)
+
+// gap between the last timestamp sent and the next one after
+// switching between publishers and the slate
+const slateGap = 40
+
+// fallback keeps the clients of a dropped publisher connected and
+// sends them the slate until the publisher comes back or the grace period ends
+type fallback struct {
+	log      *log.Logger
+	key      string
+	conn     Connection
+	clients  []Channel
+	streamID uint32
+
+	mu sync.Mutex
+	// last timestamp sent to the clients
+	last uint32
+	// the grace period is over but a publisher had already taken it
+	expired bool
+
+	quit chan struct{}
+	done chan struct{}
+}
+
+// startFallback moves the clients of the dropped publisher c to the slate
+func (ctx *StreamContext) startFallback(c *Connection) {
+	tags, err := slateTags(settings.FallbackSettings().Items.Slate)
+	if err != nil {
+		c.log.Println("[ERROR] fallback slate: " + err.Error())
+		c.endClients(c.Clients, c.streamID)
+		return
+	}
+
+	fb := &fallback{
+		log:      c.log,
+		key:      c.StreamKey,
+		conn:     *c,
+		clients:  c.Clients,
+		streamID: c.streamID,
+		last:     c.lastTimestamp,
+		quit:     make(chan struct{}),
+		done:     make(chan struct{}),
+	}
+	ctx.mu.Lock()
+	old := ctx.fallbacks[fb.key]
+	ctx.fallbacks[fb.key] = fb
+	ctx.mu.Unlock()
+	// should not happen as a publisher takes the fallback of its key
+	if old != nil {
+		old.stop()
+		old.exit()
+	}
+
+	c.log.Printf("publisher of %s dropped, sending the slate to %d clients\n", logging.Redact(fb.key), len(fb.clients))
+	grace := time.Duration(settings.FallbackSettings().Items.GracePeriod) * time.Second
+	go fb.run(ctx, tags, grace)
+}
+
+// slateTags returns the audio and video tags of the slate at path
+// the slate metadata would replace the one of the stream
+func slateTags(path string) ([]source.Tag, error) {
+	tags, err := source.ReadTags(path)
+	if err != nil {
+		return nil, err
+	}
+	media := tags[:0]
+	for _, tag := range tags {
+		if tag.Type == 8 || tag.Type == 9 {
+			media = append(media, tag)
+		}
+	}
+	if len(media) == 0 {
+		return nil, fmt.Errorf("%s has no audio or video", path)
+	}
+	return media, nil
+}
+
+// takeFallback removes and returns the running fallback of key if there is one
+func (ctx *StreamContext) takeFallback(key string) *fallback {
+	ctx.mu.Lock()
+	defer ctx.mu.Unlock()
+	fb := ctx.fallbacks[key]
+	delete(ctx.fallbacks, key)
+	return fb
+}
+
+// restoreFallback gives back a fallback which was taken by a publisher
+// that dropped before replacing the slate
+func (ctx *StreamContext) restoreFallback(key string, fb *fallback) {
+	fb.mu.Lock()
+	expired := fb.expired
+	fb.mu.Unlock()
+	if expired {
+		fb.stop()
+		fb.exit()
+		return
+	}
+	ctx.mu.Lock()
+	ctx.fallbacks[key] = fb
+	ctx.mu.Unlock()
+}
+
+// run loops the slate until stop is called or the grace period ends
+func (fb *fallback) run(ctx *StreamContext, tags []source.Tag, grace time.Duration) {
+	defer close(fb.done)
+	timer := time.NewTimer(grace)
+	defer timer.Stop()
+	expire := timer.C
+
+	// timestamps continue from the last one the clients got
+	base := fb.last + slateGap
+	start := time.Now()
+	for {
+		// a slate of a single frame is sent again every slateGap
+		select {
+		case <-fb.quit:
+			return
+		case <-expire:
+			expire = nil
+			if fb.expire(ctx) {
+				return
+			}
+		default:
+		}
+		var end uint32
+		for _, tag := range tags {
+			wait := time.NewTimer(time.Until(start.Add(time.Duration(tag.Timestamp) * time.Millisecond)))
+			for waiting := true; waiting; {
+				select {
+				case <-fb.quit:
+					wait.Stop()
+					return
+				case <-expire:
+					expire = nil
+					if fb.expire(ctx) {
+						wait.Stop()
+						return
+					}
+				case <-wait.C:
+					waiting = false
+				}
+			}
+			fb.send(tag, base+tag.Timestamp)
+			end = tag.Timestamp
+		}
+		base += end + slateGap
+		start = start.Add(time.Duration(end+slateGap) * time.Millisecond)
+	}
+}
+
+// expire ends the clients if no publisher has taken the fallback
+// and reports whether it did
+func (fb *fallback) expire(ctx *StreamContext) bool {
+	ctx.mu.Lock()
+	owned := ctx.fallbacks[fb.key] == fb
+	if owned {
+		delete(ctx.fallbacks, fb.key)
+	}
+	ctx.mu.Unlock()
+	if !owned {
+		fb.mu.Lock()
+		fb.expired = true
+		fb.mu.Unlock()
+		return false
+	}
+	fb.log.Printf("publisher of %s didn't come back, ending %d clients\n", logging.Redact(fb.key), len(fb.clients))
+	fb.exit()
+	return true
+}
+
+func (fb *fallback) send(tag source.Tag, timestamp uint32) {
+	csid := uint32(6)
+	if tag.Type == 8 {
+		csid = 4
+	}
+	chunk := &rtmpChunk{
+		header: &header{
+			fmt:             0,
+			csid:            csid,
+			messageType:     tag.Type,
+			messageStreamID: fb.streamID,
+			timestamp:       timestamp,
+			length:          uint32(len(tag.Data)),
+		},
+		clock:   timestamp,
+		payload: tag.Data,
+	}
+	data := bytes.Join(fb.conn.create(chunk), nil)
+	for _, client := range fb.clients {
+		client.send(data)
+	}
+	fb.mu.Lock()
+	fb.last = timestamp
+	fb.mu.Unlock()
+}
+
+// stop ends the slate and returns the last timestamp sent
+func (fb *fallback) stop() uint32 {
+	select {
+	case <-fb.quit:
+	default:
+		close(fb.quit)
+	}
+	<-fb.done
+	fb.mu.Lock()
+	defer fb.mu.Unlock()
+	return fb.last
+}
+
+func (fb *fallback) exit() {
+	fb.conn.endClients(fb.clients, fb.streamID)
+}
+
+// resumeClients replaces the slate with this publisher
+// starting from its keyframe chunk
+func (c *Connection) resumeClients(chunk *rtmpChunk) {
+	fb := c.resume
+	c.resume = nil
+	last := fb.stop()
+	defer c.lockClients()()
+	c.Clients = append(fb.clients, c.Clients...)
+	c.log.Printf("publisher of %s is back, replacing the slate\n", logging.Redact(c.StreamKey))
+	c.continueFrom(last, chunk)
+}
+
+// continueFrom makes the timestamps of this publisher continue after last
+// starting from its keyframe chunk and sends its headers to the clients
+func (c *Connection) continueFrom(last uint32, chunk *rtmpChunk) {
+	c.tsOffset = int64(last) + slateGap - int64(chunk.clock)
+
+	// the clients need the headers of the new stream before its frames
+	for _, data := range c.headerMessages(last+slateGap, chunk.header.messageStreamID) {
+		for _, client := range c.Clients {
+			client.send(data)
+		}
+	}
+}
+
+// headerMessages returns the metadata and sequence headers of the publisher
+// as messages with the given timestamp
+func (c *Connection) headerMessages(timestamp, streamID uint32) [][]byte {
+	headers := []struct {
+		csid        uint32
+		messageType uint8
+		payload     []byte
+	}{
+		{6, 18, c.MetaData},
+		{4, 8, c.FirstAudio},
+		{6, 9, c.FirstVideo},
+	}
+	var messages [][]byte
+	for _, h := range headers {
+		if len(h.payload) == 0 {
+			continue
+		}
+		msg := &rtmpChunk{
+			header: &header{
+				fmt:             0,
+				csid:            h.csid,
+				messageType:     h.messageType,
+				messageStreamID: streamID,
+				timestamp:       timestamp,
+				length:          uint32(len(h.payload)),
+			},
+			clock:   timestamp,
+			payload: h.payload,
+		}
+		messages = append(messages, bytes.Join(c.create(msg), nil))
+	}
+	return messages
+}
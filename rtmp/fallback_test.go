@@ -0,0 +1,70 @@
+package rtmp
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/nareix/joy4/format/flv/flvio"
+)
+
+// writeFLV writes a flv file of tags with the given types
+func writeFLV(t *testing.T, types ...uint8) string {
+	t.Helper()
+	b := make([]byte, flvio.FileHeaderLength+4)
+	flvio.FillFileHeader(b, flvio.FILE_HAS_AUDIO|flvio.FILE_HAS_VIDEO)
+	for i, typ := range types {
+		var header [flvio.TagHeaderLength]byte
+		n := flvio.FillTagHeader(header[:], typ, 1, int32(i*40))
+		b = append(b, header[:n]...)
+		b = append(b, byte(i))
+		var trailer [flvio.TagTrailerLength]byte
+		n = flvio.FillTagTrailer(trailer[:], 1)
+		b = append(b, trailer[:n]...)
+	}
+	path := filepath.Join(t.TempDir(), "slate.flv")
+	if err := os.WriteFile(path, b, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestSlateTags(t *testing.T) {
+	tests := []struct {
+		name  string
+		types []uint8
+		want  []uint8
+		err   string
+	}{
+		{"audio and video", []uint8{18, 9, 8, 9}, []uint8{9, 8, 9}, ""},
+		{"only audio", []uint8{8}, []uint8{8}, ""},
+		{"only metadata", []uint8{18, 18}, nil, "has no audio or video"},
+		{"no tags", nil, nil, "has no audio or video"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tags, err := slateTags(writeFLV(t, tt.types...))
+			if tt.err != "" {
+				if err == nil || !strings.HasSuffix(err.Error(), tt.err) {
+					t.Fatalf("slateTags() = %v, want %q", err, tt.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			var got []uint8
+			for _, tag := range tags {
+				got = append(got, tag.Type)
+			}
+			if string(got) != string(tt.want) {
+				t.Errorf("slateTags() types = %v, want %v", got, tt.want)
+			}
+		})
+	}
+
+	if _, err := slateTags(filepath.Join(t.TempDir(), "missing.flv")); err == nil {
+		t.Error("slateTags() of a missing file succeeded")
+	}
+}
@@ -0,0 +1,113 @@
+package rtmp
+
+import (
+	"expvar"
+	"net"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/alipourhabibi/restream/settings"
+)
+
+// metrics of the rejected connections which are served in /debug/vars
+var metrics = expvar.NewMap("rtmp")
+
+// rateLimiter has a token bucket for each ip
+type rateLimiter struct {
+	mu      sync.Mutex
+	buckets map[string]*bucket
+	pruned  time.Time
+}
+
+type bucket struct {
+	tokens float64
+	last   time.Time
+}
+
+// allow takes a token of ip, the bucket gets rate tokens a second up to burst
+func (r *rateLimiter) allow(ip string, rate float64, burst int) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	now := time.Now()
+	if r.buckets == nil {
+		r.buckets = make(map[string]*bucket)
+	}
+	b, ok := r.buckets[ip]
+	if !ok {
+		// the full buckets are the same as no bucket, they're
+		// removed so the map doesn't grow with every ip
+		if now.Sub(r.pruned) > time.Second {
+			r.prune(now, rate, burst)
+			r.pruned = now
+		}
+		b = &bucket{tokens: float64(burst), last: now}
+		r.buckets[ip] = b
+	}
+	b.tokens += now.Sub(b.last).Seconds() * rate
+	if b.tokens > float64(burst) {
+		b.tokens = float64(burst)
+	}
+	b.last = now
+	if b.tokens < 1 {
+		return false
+	}
+	b.tokens--
+	return true
+}
+
+func (r *rateLimiter) prune(now time.Time, rate float64, burst int) {
+	for ip, b := range r.buckets {
+		if b.tokens+now.Sub(b.last).Seconds()*rate >= float64(burst) {
+			delete(r.buckets, ip)
+		}
+	}
+}
+
+// admit reports why a new connection is rejected by the limits
+// or an empty string when it's accepted
+func (s *Stream) admit(conn net.Conn) string {
+	limits := settings.LimitsSettings().Items
+	s.mu.Lock()
+	open := len(s.conns)
+	s.mu.Unlock()
+	if limits.MaxConnections > 0 && open >= limits.MaxConnections {
+		return "connections"
+	}
+	if limits.RateLimit > 0 {
+		ip, _, err := net.SplitHostPort(conn.RemoteAddr().String())
+		if err != nil {
+			ip = conn.RemoteAddr().String()
+		}
+		if !s.limiter.allow(ip, limits.RateLimit, limits.RateBurst) {
+			return "rate"
+		}
+	}
+	return ""
+}
+
+// keepAlive sets the tcp keepalive of conn as the limits section says
+func keepAlive(conn net.Conn) {
+	tcp, ok := conn.(*net.TCPConn)
+	if !ok {
+		return
+	}
+	period := time.Duration(settings.LimitsSettings().Items.KeepAlive) * time.Millisecond
+	tcp.SetKeepAlive(period > 0)
+	if period > 0 {
+		tcp.SetKeepAlivePeriod(period)
+	}
+}
+
+// acquire takes one of max slots of count, 0 max is unlimited
+func acquire(count *int64, max int) bool {
+	for {
+		n := atomic.LoadInt64(count)
+		if max > 0 && n >= int64(max) {
+			return false
+		}
+		if atomic.CompareAndSwapInt64(count, n, n+1) {
+			return true
+		}
+	}
+}
@@ -0,0 +1,98 @@
+package rtmp
+
+import (
+	"expvar"
+	"fmt"
+	"time"
+
+	"github.com/alipourhabibi/restream/events"
+)
+
+// violations counts the requirements broken by each destination
+var violations = func() *expvar.Map {
+	m := new(expvar.Map).Init()
+	metrics.Set("violations", m)
+	return m
+}()
+
+// violation is a requirement the stream breaks
+type violation struct {
+	kind    string
+	message string
+}
+
+// check returns the requirements which the stream with st and codec breaks
+// the ones the stream has no stats of yet are skipped
+func (r *Requirements) check(st Stats, codec *events.Codec) []violation {
+	var found []violation
+	if bitrate := (st.VideoBitrate + st.AudioBitrate) / 1000; r.MaxBitrate > 0 && bitrate > int64(r.MaxBitrate) {
+		found = append(found, violation{"bitrate", fmt.Sprintf("bitrate %d kbps is above %d kbps", bitrate, r.MaxBitrate)})
+	}
+	// a frame more is allowed for the rounding of the timestamps like 2002ms of 29.97 fps
+	var frame int64
+	if st.FPS > 0 {
+		frame = int64(1000 / st.FPS)
+	}
+	if r.KeyframeInterval > 0 && st.GOPDuration > int64(r.KeyframeInterval)+frame {
+		found = append(found, violation{"keyframe", fmt.Sprintf("keyframe interval %dms is above %dms", st.GOPDuration, r.KeyframeInterval)})
+	}
+	if codec == nil {
+		return found
+	}
+	if (r.MaxWidth > 0 && codec.Width > r.MaxWidth) || (r.MaxHeight > 0 && codec.Height > r.MaxHeight) {
+		found = append(found, violation{"resolution", fmt.Sprintf("resolution %dx%d is above %dx%d", codec.Width, codec.Height, r.MaxWidth, r.MaxHeight)})
+	}
+	if codec.Video != "" && len(r.VideoCodecs) > 0 && !contains(r.VideoCodecs, codec.Video) {
+		found = append(found, violation{"video", fmt.Sprintf("video codec %s is not one of %v", codec.Video, r.VideoCodecs)})
+	}
+	if codec.Audio != "" && len(r.AudioCodecs) > 0 && !contains(r.AudioCodecs, codec.Audio) {
+		found = append(found, violation{"audio", fmt.Sprintf("audio codec %s is not one of %v", codec.Audio, r.AudioCodecs)})
+	}
+	return found
+}
+
+func contains(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
+
+// checkRequirements compares the stream of the active publisher with the
+// requirements of d, a violation is reported when it starts and logged
+// again when it ends
+func (d *destination) checkRequirements() {
+	c := d.ctx.get(d.base.Key)
+	if c == nil {
+		return
+	}
+	found := d.requirements.check(c.stats.get(), c.stats.getCodec())
+	active := make(map[string]bool, len(found))
+	for _, v := range found {
+		active[v.kind] = true
+		if d.violations[v.kind] {
+			continue
+		}
+		d.log.Printf("[WARNING] destination %s requirement failed: %s\n", d.base.Destination, v.message)
+		violations.Add(d.base.Destination+"."+v.kind, 1)
+		d.publish(events.DestinationViolation, fmt.Errorf("%s", v.message))
+	}
+	for kind := range d.violations {
+		if !active[kind] {
+			d.log.Printf("destination %s meets its %s requirement again\n", d.base.Destination, kind)
+		}
+	}
+	d.violations = active
+}
+
+// requirementTicker ticks when d has requirements to check, it's
+// stopped by the returned func
+func (d *destination) requirementTicker() (<-chan time.Time, func()) {
+	if d.requirements == nil {
+		return nil, func() {}
+	}
+	ticker := time.NewTicker(statsWindow)
+	return ticker.C, ticker.Stop
+}
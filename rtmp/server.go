@@ -2,66 +2,174 @@ package rtmp
 
 import (
 	"bufio"
+	"expvar"
 	"fmt"
 	"log"
 	"net"
+	"strconv"
+	"sync"
 
-	"github.com/alipourhabibi/restream/grpcclient"
-	protos "github.com/alipourhabibi/restream/protos/usersinfo"
+	"github.com/alipourhabibi/restream/acl"
+	"github.com/alipourhabibi/restream/auth"
+	"github.com/alipourhabibi/restream/events"
+	"github.com/alipourhabibi/restream/logging"
 	"github.com/alipourhabibi/restream/settings"
 )
 
 // Stream is the entrypoint for handling incoming rtmp request for streaming
 type Stream struct {
-	log *log.Logger
-	RPC protos.UsersInfoClient
+	logs   *logging.Logger
+	log    *log.Logger
+	Events *events.Dispatcher
+	// the authenticator is replaced when the config is reloaded
+	mu   sync.Mutex
+	auth auth.Authenticator
+	// writes a line for every closed connection when it's set
+	access *logging.Logger
+	// listener and the open connections which are closed on shutdown
+	ln    net.Listener
+	conns map[*Connection]bool
+	// ids of the connections in the logs
+	lastID  uint64
+	limiter rateLimiter
+	// shared by every connection so players and republishing
+	// publishers can find the stream of a key
+	ctx *StreamContext
 }
 
 // NewStream returns Steam struct which is for starting streaming service
-func NewStream(log *log.Logger) *Stream {
-	uInfo := grpcclient.NewUsersInfo(log)
-	client := uInfo.GetClient()
-	return &Stream{
-		log: log,
-		RPC: client,
+func NewStream(logs *logging.Logger, authenticator auth.Authenticator, dispatcher *events.Dispatcher, services *Services) *Stream {
+	s := &Stream{
+		logs:   logs,
+		log:    logs.For(logging.Restream, nil),
+		auth:   authenticator,
+		Events: dispatcher,
+		conns:  make(map[*Connection]bool),
+		ctx: &StreamContext{
+			sessions:  make(map[string]*Connection),
+			fallbacks: make(map[string]*fallback),
+			failovers: make(map[string]*failover),
+			plays:     make(map[string]string),
+			services:  services,
+		},
 	}
+	metrics.Set("streams", expvar.Func(s.stats))
+	return s
+}
+
+// SetAuth authorizes the next clients with a
+func (s *Stream) SetAuth(a auth.Authenticator) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.auth = a
+}
+
+func (s *Stream) authenticator() auth.Authenticator {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.auth
+}
+
+// SetAccessLog writes a line for every connection closed from now on to a
+func (s *Stream) SetAccessLog(a *logging.Logger) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.access = a
+}
+
+// SetServices sends the next publishers to the servers of services
+func (s *Stream) SetServices(services *Services) {
+	s.ctx.mu.Lock()
+	defer s.ctx.mu.Unlock()
+	s.ctx.services = services
 }
 
 // InitStream is  where we start server for streamming
-func (s *Stream) InitStream() {
+// it returns when the server can't listen or after Shutdown
+func (s *Stream) InitStream() error {
 
 	var ln net.Listener
 	var err error
-	laddr := fmt.Sprintf(":%d", settings.ServerSettings.Items.Port)
+	laddr := fmt.Sprintf(":%d", settings.ServerSettings().Items.Port)
 	ln, err = net.Listen("tcp", laddr)
 	if err != nil {
-		panic(err)
+		return err
 	}
 	defer ln.Close()
+	s.mu.Lock()
+	s.ln = ln
+	s.mu.Unlock()
+
 	for {
 		conn, err := ln.Accept()
 		if err != nil {
+			if s.ctx.isClosing() {
+				return nil
+			}
 			s.log.Printf(err.Error())
 			continue
 		}
 
-		ctx := &StreamContext{}
-		ctx.sessions = make(map[string]*Connection)
-
+		if !acl.Accepted(conn.RemoteAddr().String()) {
+			s.log.Printf("[WARNING] rejected %s, it can't publish or play in any app\n", conn.RemoteAddr())
+			conn.Close()
+			continue
+		}
+		if reason := s.admit(conn); reason != "" {
+			metrics.Add("rejected."+reason, 1)
+			s.log.Printf("[DEBUG] rejected %s, over the %s limit\n", conn.RemoteAddr(), reason)
+			conn.Close()
+			continue
+		}
+		keepAlive(conn)
+		conn = &countConn{Conn: conn}
+		s.lastID++
+		fields := logging.NewFields("conn", strconv.FormatUint(s.lastID, 10), "remote", conn.RemoteAddr().String())
 		c := &Connection{
-			log:               s.log,
+			log:               s.logs.For(logging.Restream, fields),
+			handshakeLog:      s.logs.For(logging.Handshake, fields),
+			chunkLog:          s.logs.For(logging.Chunk, fields),
+			amfLog:            s.logs.For(logging.AMF, fields),
+			fields:            fields,
 			Conn:              conn,
 			Reader:            bufio.NewReader(conn),
 			Writer:            bufio.NewWriter(conn),
 			ReadBuffer:        make([]byte, 5096),
 			WriteBuffer:       make([]byte, 5096),
 			csMap:             make(map[uint32]*rtmpChunk),
-			RPC:               s.RPC,
+			Auth:              s.authenticator(),
+			Events:            s.Events,
 			ReadMaxChunkSize:  128,
 			WriteMaxChunkSize: 4096,
 			Stage:             handshakeStage,
-			Context:           ctx,
+			Context:           s.ctx,
+			clientsMu:         &sync.Mutex{},
 		}
-		go c.Handle()
+		s.mu.Lock()
+		c.access = s.access
+		s.conns[c] = true
+		s.mu.Unlock()
+		go func() {
+			c.Handle()
+			s.mu.Lock()
+			delete(s.conns, c)
+			s.mu.Unlock()
+		}()
+	}
+}
+
+// Failovers returns the state of the stream keys with a backup publisher
+func (s *Stream) Failovers() []FailoverStatus {
+	s.ctx.mu.Lock()
+	failovers := make([]*failover, 0, len(s.ctx.failovers))
+	for _, g := range s.ctx.failovers {
+		failovers = append(failovers, g)
+	}
+	s.ctx.mu.Unlock()
+
+	statuses := make([]FailoverStatus, 0, len(failovers))
+	for _, g := range failovers {
+		statuses = append(statuses, g.status())
 	}
+	return statuses
 }
@@ -0,0 +1,112 @@
+package rtmp
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"strings"
+)
+
+// Requirements are the limits of the ingest servers of a service
+// which are reported when the stream breaks them, 0 or empty is no limit
+type Requirements struct {
+	// kbps of the audio and video together
+	MaxBitrate int `json:"maxBitrate,omitempty"`
+	MaxWidth   int `json:"maxWidth,omitempty"`
+	MaxHeight  int `json:"maxHeight,omitempty"`
+	// longest time between keyframes in milliseconds
+	KeyframeInterval int      `json:"keyframeInterval,omitempty"`
+	VideoCodecs      []string `json:"videoCodecs,omitempty"`
+	AudioCodecs      []string `json:"audioCodecs,omitempty"`
+}
+
+// LoadServices reads the catalog of the servers in path
+// every service needs a name and its servers need rtmp urls
+func LoadServices(path string) (*Services, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	services := &Services{}
+	if err := json.Unmarshal(data, services); err != nil {
+		return nil, fmt.Errorf("%s: %v", path, err)
+	}
+	for _, service := range services.Services {
+		if service.Name == "" {
+			return nil, fmt.Errorf("%s: service without a Name", path)
+		}
+		if len(service.Servers) == 0 {
+			return nil, fmt.Errorf("%s: %s has no servers", path, service.Name)
+		}
+		for _, server := range service.Servers {
+			u, err := url.Parse(server.URL)
+			if err != nil || (u.Scheme != "rtmp" && u.Scheme != "rtmps") || u.Host == "" {
+				return nil, fmt.Errorf("%s: %s has an invalid url %q", path, service.Name, server.URL)
+			}
+		}
+		if err := service.Requirements.validate(); err != nil {
+			return nil, fmt.Errorf("%s: %s %v", path, service.Name, err)
+		}
+	}
+	return services, nil
+}
+
+func (r *Requirements) validate() error {
+	if r == nil {
+		return nil
+	}
+	if r.MaxBitrate < 0 || r.MaxWidth < 0 || r.MaxHeight < 0 || r.KeyframeInterval < 0 {
+		return errors.New("has negative requirements")
+	}
+	for _, codec := range r.VideoCodecs {
+		if !known(videoCodecs, codec) {
+			return fmt.Errorf("requires an unknown video codec %q", codec)
+		}
+	}
+	for _, codec := range r.AudioCodecs {
+		if !known(audioCodecs, codec) {
+			return fmt.Errorf("requires an unknown audio codec %q", codec)
+		}
+	}
+	return nil
+}
+
+func known(codecs map[byte]string, name string) bool {
+	for _, codec := range codecs {
+		if codec == name {
+			return true
+		}
+	}
+	return false
+}
+
+// server returns the first server of the service with the name
+func (s *Services) server(name string) (Servers, bool) {
+	if s == nil {
+		return Servers{}, false
+	}
+	for _, service := range s.Services {
+		if service.Name == name {
+			return service.Servers[0], true
+		}
+	}
+	return Servers{}, false
+}
+
+// requirements returns the requirements of the service which has
+// a server at the start of url, nil when there isn't one
+func (s *Services) requirements(url string) *Requirements {
+	if s == nil {
+		return nil
+	}
+	for _, service := range s.Services {
+		for _, server := range service.Servers {
+			if url == server.URL || strings.HasPrefix(url, strings.TrimSuffix(server.URL, "/")+"/") {
+				return service.Requirements
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,160 @@
+package rtmp
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"net/url"
+	"path"
+	"sync/atomic"
+	"time"
+
+	"github.com/alipourhabibi/restream/amf"
+	"github.com/nareix/joy4/format/flv/flvio"
+)
+
+// roles of a connection once its command stage is done
+const (
+	rolePublisher = iota + 1
+	rolePlayer
+)
+
+// isClosing reports whether new publishers and players should be rejected
+func (ctx *StreamContext) isClosing() bool {
+	return atomic.LoadInt32(&ctx.closing) == 1
+}
+
+// Shutdown stops accepting connections and ends every stream like its publisher
+// stopped, so the players are notified, the destinations are unpublished and
+// the recordings are finalized. It waits for them until ctx is done and then
+// closes the connections which are left
+func (s *Stream) Shutdown(ctx context.Context) error {
+	atomic.StoreInt32(&s.ctx.closing, 1)
+	s.mu.Lock()
+	if s.ln != nil {
+		s.ln.Close()
+	}
+	conns := make([]*Connection, 0, len(s.conns))
+	for c := range s.conns {
+		conns = append(conns, c)
+	}
+	s.mu.Unlock()
+
+	// connections which aren't publishing or playing have nothing to drain
+	for _, c := range conns {
+		if atomic.LoadInt32(&c.role) == 0 {
+			c.Conn.Close()
+		}
+	}
+
+	s.ctx.mu.Lock()
+	keys := make([]string, 0, len(s.ctx.sessions)+len(s.ctx.fallbacks))
+	for key := range s.ctx.sessions {
+		keys = append(keys, key)
+	}
+	for key := range s.ctx.fallbacks {
+		if s.ctx.sessions[key] == nil {
+			keys = append(keys, key)
+		}
+	}
+	s.ctx.mu.Unlock()
+	for _, key := range keys {
+		s.end(key, "shutdown", "server is shutting down")
+	}
+
+	ticker := time.NewTicker(100 * time.Millisecond)
+	defer ticker.Stop()
+	for {
+		s.mu.Lock()
+		left := len(s.conns)
+		s.mu.Unlock()
+		if left == 0 && atomic.LoadInt64(&s.ctx.destinations) == 0 {
+			return nil
+		}
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			s.mu.Lock()
+			for c := range s.conns {
+				c.Conn.Close()
+			}
+			s.mu.Unlock()
+			return ctx.Err()
+		}
+	}
+}
+
+// endClients tells the players that the stream is unpublished and
+// stops the players and destinations
+func (c Connection) endClients(clients []Channel, streamID uint32) {
+	notify := c.unpublishNotify(streamID)
+	for _, client := range clients {
+		if client.ChannelName == playerChannel {
+			client.send(notify)
+		}
+		client.exit()
+	}
+}
+
+// unpublishNotify returns the onStatus and StreamEOF messages a server
+// sends to the players when the publisher of their stream stops
+func (c Connection) unpublishNotify(streamID uint32) []byte {
+	info := flvio.AMFMap{
+		"level":       "status",
+		"code":        "NetStream.Play.UnpublishNotify",
+		"description": "Stream is now unpublished",
+	}
+	amfPayload, _ := amf.Encode("onStatus", 0, nil, info)
+	status := c.create(&rtmpChunk{
+		header: &header{
+			fmt:             0,
+			csid:            5,
+			messageType:     20,
+			messageStreamID: streamID,
+			length:          uint32(len(amfPayload)),
+		},
+		payload: amfPayload,
+	})
+
+	// user control event 1 is StreamEOF
+	b := make([]byte, 6)
+	binary.BigEndian.PutUint16(b[:2], 1)
+	binary.BigEndian.PutUint32(b[2:], streamID)
+	eof := c.create(&rtmpChunk{
+		header: &header{
+			fmt:         0,
+			csid:        2,
+			messageType: 4,
+			length:      uint32(len(b)),
+		},
+		payload: b,
+	})
+	return bytes.Join(append(status, eof...), nil)
+}
+
+// unpublish returns the FCUnpublish and deleteStream commands an encoder
+// sends when it stops publishing to the destination at rawURL
+func (c Connection) unpublish(rawURL string) []byte {
+	name := ""
+	if u, err := url.Parse(rawURL); err == nil {
+		name = path.Base(u.Path)
+	}
+	var messages [][]byte
+	for _, command := range [][]interface{}{
+		{"FCUnpublish", 0, nil, name},
+		// the first stream of a connection has id 1
+		{"deleteStream", 0, nil, 1},
+	} {
+		amfPayload, _ := amf.Encode(command...)
+		messages = append(messages, c.create(&rtmpChunk{
+			header: &header{
+				fmt:         0,
+				csid:        3,
+				messageType: 20,
+				length:      uint32(len(amfPayload)),
+			},
+			payload: amfPayload,
+		})...)
+	}
+	return bytes.Join(messages, nil)
+}
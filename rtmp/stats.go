@@ -0,0 +1,223 @@
+package rtmp
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/alipourhabibi/restream/events"
+)
+
+// statsWindow is how long the bitrates and the frame rate are averaged over
+const statsWindow = 5 * time.Second
+
+// Stats describes the media a publisher is sending, the rates are of
+// the last statsWindow and the timestamps are in milliseconds
+type Stats struct {
+	VideoBitrate int64   `json:"videoBitrate"`
+	AudioBitrate int64   `json:"audioBitrate"`
+	FPS          float64 `json:"fps"`
+	// frames and duration of the last complete group of pictures
+	GOPFrames   int   `json:"gopFrames"`
+	GOPDuration int64 `json:"gopDuration"`
+	// smoothed difference between the arrival times and the timestamps
+	// of the video frames as in rfc 3550, of the audio without video
+	Jitter float64 `json:"jitter"`
+	// last audio timestamp minus the last video timestamp
+	Drift int64 `json:"drift"`
+	// messages with a timestamp before the previous one of their type
+	NonMonotonic int64 `json:"nonMonotonic"`
+}
+
+func (s Stats) String() string {
+	return fmt.Sprintf("video %d kbps %.1f fps gop %d frames %dms, audio %d kbps, jitter %.1fms, drift %dms, %d non-monotonic timestamps",
+		s.VideoBitrate/1000, s.FPS, s.GOPFrames, s.GOPDuration, s.AudioBitrate/1000, s.Jitter, s.Drift, s.NonMonotonic)
+}
+
+// stats returns the Stats of the publishers by their play names
+// for the metrics, the stream keys aren't in them
+func (s *Stream) stats() interface{} {
+	streams := make(map[string]Stats)
+	for _, info := range s.Streams() {
+		streams[info.Play] = info.Stats
+	}
+	return streams
+}
+
+// sample is a message in the window
+type sample struct {
+	at    time.Time
+	video bool
+	frame bool
+	bytes int
+}
+
+// stats computes the Stats of a publisher from its audio and video messages
+// they're added by the goroutine of the publisher and read by the others
+type stats struct {
+	mu      sync.Mutex
+	start   time.Time
+	samples []sample
+
+	gopFrames   int
+	gopDuration int64
+	// frames since the last keyframe and its timestamp
+	frames   int
+	keyframe int64
+	hasKey   bool
+
+	audioJitter, videoJitter jitter
+
+	lastAudio, lastVideo int64
+	hasAudio, hasVideo   bool
+	nonMonotonic         int64
+
+	// of the first audio and video messages
+	codec *events.Codec
+}
+
+// add counts a message of type 8 or 9 with its timestamp
+func (st *stats) add(video bool, timestamp uint32, payload []byte) {
+	now := time.Now()
+	ts := int64(timestamp)
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	if st.start.IsZero() {
+		st.start = now
+	}
+
+	frame := video && isFrame(payload)
+	st.samples = append(st.samples, sample{at: now, video: video, frame: frame, bytes: len(payload)})
+	drop := 0
+	for drop < len(st.samples) && now.Sub(st.samples[drop].at) > statsWindow {
+		drop++
+	}
+	st.samples = st.samples[drop:]
+
+	if frame {
+		if payload[0]>>4 == 1 {
+			if st.hasKey {
+				st.gopFrames = st.frames
+				st.gopDuration = ts - st.keyframe
+			}
+			st.frames = 0
+			st.keyframe = ts
+			st.hasKey = true
+		}
+		st.frames++
+		st.videoJitter.add(now, ts)
+	} else if !video {
+		st.audioJitter.add(now, ts)
+	}
+
+	if video {
+		if st.hasVideo && ts < st.lastVideo {
+			st.nonMonotonic++
+		}
+		st.lastVideo, st.hasVideo = ts, true
+	} else {
+		if st.hasAudio && ts < st.lastAudio {
+			st.nonMonotonic++
+		}
+		st.lastAudio, st.hasAudio = ts, true
+	}
+}
+
+// get returns the Stats of the messages added so far, nil has none
+func (st *stats) get() Stats {
+	if st == nil {
+		return Stats{}
+	}
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	s := Stats{
+		GOPFrames:    st.gopFrames,
+		GOPDuration:  st.gopDuration,
+		Jitter:       st.audioJitter.value,
+		NonMonotonic: st.nonMonotonic,
+	}
+	if st.hasVideo {
+		s.Jitter = st.videoJitter.value
+	}
+	if st.hasAudio && st.hasVideo {
+		s.Drift = st.lastAudio - st.lastVideo
+	}
+	// a publisher which started recently has a shorter window
+	window := statsWindow
+	if since := time.Since(st.start); since < window {
+		window = since
+	}
+	if window <= 0 {
+		return s
+	}
+	var audio, video, frames int
+	for _, m := range st.samples {
+		if time.Since(m.at) > statsWindow {
+			continue
+		}
+		if m.video {
+			video += m.bytes
+		} else {
+			audio += m.bytes
+		}
+		if m.frame {
+			frames++
+		}
+	}
+	s.VideoBitrate = int64(float64(video*8) / window.Seconds())
+	s.AudioBitrate = int64(float64(audio*8) / window.Seconds())
+	s.FPS = float64(frames) / window.Seconds()
+	return s
+}
+
+// setCodec keeps the codec for the readers once a header is received
+func (st *stats) setCodec(codec *events.Codec) {
+	if st == nil {
+		return
+	}
+	st.mu.Lock()
+	st.codec = codec
+	st.mu.Unlock()
+}
+
+// getCodec returns the codec or nil before the first header
+func (st *stats) getCodec() *events.Codec {
+	if st == nil {
+		return nil
+	}
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	return st.codec
+}
+
+// jitter is the interarrival jitter of rfc 3550 in milliseconds
+type jitter struct {
+	value   float64
+	arrival time.Time
+	last    int64
+	started bool
+}
+
+func (j *jitter) add(now time.Time, ts int64) {
+	if j.started {
+		d := float64(now.Sub(j.arrival).Milliseconds()) - float64(ts-j.last)
+		if d < 0 {
+			d = -d
+		}
+		j.value += (d - j.value) / 16
+	}
+	j.arrival, j.last, j.started = now, ts, true
+}
+
+// isFrame reports whether a video payload is a frame and not
+// the sequence header or the end of the sequence of h264 or hevc
+func isFrame(payload []byte) bool {
+	if len(payload) < 2 {
+		return false
+	}
+	switch payload[0] & 0x0f {
+	case 7, 12:
+		return payload[1] == 1
+	}
+	return true
+}
@@ -1,11 +1,10 @@
 package rtmp
 
 import (
-	"bufio"
+	"crypto/rand"
 	"encoding/hex"
-	"fmt"
-
-	"github.com/praveen001/joy4/format/rtmp"
+	"net"
+	"strings"
 )
 
 func (c *Connection) setMaxWriteChunkSize(size uint16) {
@@ -29,28 +28,24 @@ func (c *Connection) setPeerBandwidth(size uint32, limit uint8) {
 	}
 }
 
-func (c *Connection) prepareClient(url string, ch Channel) {
-	fmt.Println(url)
-	client, err := rtmp.Dial(url)
+// newSessionID returns a random id to tell publish sessions apart
+func newSessionID() string {
+	b := make([]byte, 8)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// remoteIP returns the ip address of the other side of the connection
+func (c *Connection) remoteIP() string {
+	host, _, err := net.SplitHostPort(c.Conn.RemoteAddr().String())
 	if err != nil {
-		// Probably invalid Key
-		return
+		return c.Conn.RemoteAddr().String()
 	}
-	client.Prepare()
-
-	clientWriter := bufio.NewWriter(client.NetConn())
+	return host
+}
 
-	go func() {
-		for {
-			select {
-			case chunk := <-ch.Send:
-				clientWriter.Write(chunk)
-			case <-ch.Exit:
-				client.WriteTrailer()
-				client.Close()
-				return
-			}
-			clientWriter.Flush()
-		}
-	}()
+// splitStreamName returns the key and the query of a stream name like key?exp=...
+func splitStreamName(name string) (string, string) {
+	key, query, _ := strings.Cut(name, "?")
+	return key, query
 }
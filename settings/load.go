@@ -0,0 +1,192 @@
+package settings
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"sync/atomic"
+
+	gcfg "gopkg.in/gcfg.v1"
+)
+
+// mu is held while the settings are replaced
+var mu sync.Mutex
+
+// current is the set of sections which the settings are read from,
+// a set is never changed once it's stored so it's read without a lock
+var current atomic.Pointer[[]interface{}]
+
+func init() {
+	set := defaults()
+	current.Store(&set)
+}
+
+// sections are the settings of every section in the order of conf/conf.ini
+func sections() []interface{} {
+	return *current.Load()
+}
+
+// defaults returns every section with the values its keys have
+// when they're not in the file, in the order of sections
+func defaults() []interface{} {
+	return []interface{}{
+		&server{serverItems{RunMode: "Debug", Port: 1935, Services: "services/servers.json", DrainTimeout: 10}},
+		&gRPCUsersInfo{gRPCUsersInfoItems{
+			Host:            "localhost",
+			Port:            4005,
+			Timeout:         3000,
+			Retries:         2,
+			RetryBackoff:    200,
+			BreakerFailures: 5,
+			BreakerCooldown: 30,
+			BreakerPolicy:   "deny",
+			LastGoodTTL:     3600,
+			CAFile:          "certfiles/grpc/cert.pem",
+		}},
+		&record{recordItems{
+			Apps:      "*",
+			Path:      "recordings",
+			FileName:  "{app}_{key}_{date}_{session}_{part}",
+			Format:    "flv",
+			FastStart: true,
+		}},
+		&admin{adminItems{Host: "127.0.0.1", Port: 8080, SourcesDir: "sources"}},
+		&fallback{},
+		&failover{failoverItems{BackupSuffix: "_backup", StallTimeout: 2000}},
+		&auth{authItems{Backend: "grpc", File: "conf/keys.yaml", WebhookTimeout: 5000}},
+		&token{},
+		&events{eventsItems{Buffer: 256}},
+		&webhooks{webhooksItems{Timeout: 5000, Retries: 3, RetryBackoff: 1000, Queue: 256}},
+		&control{controlItems{
+			Host:         "127.0.0.1",
+			Port:         4006,
+			CertFile:     "certfiles/grpc/cert.pem",
+			KeyFile:      "certfiles/grpc/key.pem",
+			ClientCAFile: "certfiles/grpc/client-cert.pem",
+		}},
+		&logs{logsItems{
+			Format:     "logfmt",
+			Level:      "info",
+			File:       "general.log",
+			AccessFile: "access.log",
+			MaxSize:    100,
+			MaxFiles:   7,
+		}},
+		&limits{limitsItems{
+			RateBurst:        10,
+			HandshakeTimeout: 5000,
+			CommandTimeout:   10000,
+			IdleTimeout:      10000,
+			WriteTimeout:     10000,
+			KeepAlive:        15000,
+		}},
+		&acl{},
+	}
+}
+
+// find returns the section of type T in set
+func find[T any](set []interface{}) *T {
+	for _, section := range set {
+		if t, ok := section.(*T); ok {
+			return t
+		}
+	}
+	panic("settings: missing section")
+}
+
+// Load reads the settings and validates the result, the later ones win:
+//  1. the defaults
+//  2. the file at path
+//  3. the RESTREAM_SECTION_KEY environment variables
+//
+// unknown sections and keys of the file are reported with their line numbers
+// the settings are only replaced when all of them are valid
+func Load(path string) error {
+	set, err := read(path)
+	if err != nil {
+		return err
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	current.Store(&set)
+	return nil
+}
+
+// read loads the file into a new set of sections
+func read(path string) ([]interface{}, error) {
+	set := defaults()
+	for _, section := range set {
+		// every section is read on its own so the others are extra data
+		if err := gcfg.FatalOnly(gcfg.ReadFileInto(section, path)); err != nil {
+			return nil, fmt.Errorf("%s: %v", path, err)
+		}
+	}
+	if err := applyEnv(set); err != nil {
+		return nil, err
+	}
+	problems, err := unknownKeys(path)
+	if err != nil {
+		return nil, err
+	}
+	problems = append(problems, validate(set)...)
+	if len(problems) > 0 {
+		return nil, problems
+	}
+	return set, nil
+}
+
+// knownKeys returns the lower cased keys of every section by its name
+func knownKeys() map[string]map[string]bool {
+	known := make(map[string]map[string]bool)
+	for _, section := range sections() {
+		name, items := sectionItems(section)
+		keys := make(map[string]bool)
+		for i := 0; i < items.NumField(); i++ {
+			keys[strings.ToLower(items.Type().Field(i).Tag.Get("gcfg"))] = true
+		}
+		known[strings.ToLower(name)] = keys
+	}
+	return known
+}
+
+// unknownKeys finds the sections and keys of the file which aren't settings
+// gcfg ignores them, so a typo would leave the default in place silently
+func unknownKeys(path string) (Problems, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	known := knownKeys()
+	var problems Problems
+	var section string
+	scanner := bufio.NewScanner(file)
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" || text[0] == ';' || text[0] == '#' {
+			continue
+		}
+		if text[0] == '[' {
+			section = ""
+			if fields := strings.Fields(strings.Trim(text, "[]")); len(fields) > 0 {
+				section = strings.ToLower(fields[0])
+			}
+			if _, ok := known[section]; !ok {
+				problems = append(problems, fmt.Sprintf("%s:%d: unknown section [%s]", path, line, section))
+			}
+			continue
+		}
+		keys, ok := known[section]
+		if !ok {
+			continue
+		}
+		name := strings.TrimSpace(strings.SplitN(text, "=", 2)[0])
+		if !keys[strings.ToLower(name)] {
+			problems = append(problems, fmt.Sprintf("%s:%d: unknown key %s in [%s]", path, line, name, section))
+		}
+	}
+	return problems, scanner.Err()
+}
@@ -0,0 +1,101 @@
+package settings
+
+// Change is a key which has a new value after a reload
+// Restart is set when the new value is only used after a restart
+type Change struct {
+	Section string `json:"section"`
+	Key     string `json:"key"`
+	Old     string `json:"old"`
+	New     string `json:"new"`
+	Restart bool   `json:"restart"`
+}
+
+// restartSections are only read on startup, the others are read
+// for every new connection or rebuilt by the reload
+var restartSections = map[string]bool{
+	"server":   true,
+	"admin":    true,
+	"control":  true,
+	"events":   true,
+	"webhooks": true,
+}
+
+// liveKeys are reloaded although their section isn't
+var liveKeys = map[string]bool{
+	"server.Services":     true,
+	"server.DrainTimeout": true,
+}
+
+// restartKeys are only read on startup although their section isn't
+var restartKeys = map[string]bool{
+	"log.File":           true,
+	"log.AccessFile":     true,
+	"log.MaxSize":        true,
+	"log.RotateInterval": true,
+	"log.MaxFiles":       true,
+}
+
+// restart reports whether the key of the section is only read on startup
+func restart(section, key string) bool {
+	return restartSections[section] && !liveKeys[section+"."+key] || restartKeys[section+"."+key]
+}
+
+// Reload reads the file like Load and replaces the settings with it
+// the keys which need a restart keep their values until then
+// apply is called with the changes after they're replaced, the old
+// settings are put back when the file is invalid or apply fails
+func Reload(path string, apply func([]Change) error) ([]Change, error) {
+	set, err := read(path)
+	if err != nil {
+		return nil, err
+	}
+	mu.Lock()
+	defer mu.Unlock()
+
+	old := sections()
+	changes := diff(old, set)
+	keep(old, set)
+	current.Store(&set)
+	if err := apply(changes); err != nil {
+		current.Store(&old)
+		return nil, err
+	}
+	return changes, nil
+}
+
+// keep copies the values of the keys which need a restart from old to set
+// so the settings agree with what the server is running with
+func keep(old, set []interface{}) {
+	for i := range old {
+		name, before := sectionItems(old[i])
+		_, after := sectionItems(set[i])
+		for j := 0; j < before.NumField(); j++ {
+			if restart(name, before.Type().Field(j).Tag.Get("gcfg")) {
+				after.Field(j).Set(before.Field(j))
+			}
+		}
+	}
+}
+
+// diff returns the keys which have different values in the two sets
+func diff(old, set []interface{}) []Change {
+	var changes []Change
+	for i := range old {
+		name, before := sectionItems(old[i])
+		_, after := sectionItems(set[i])
+		for j := 0; j < before.NumField(); j++ {
+			key := before.Type().Field(j).Tag.Get("gcfg")
+			if before.Field(j).Interface() == after.Field(j).Interface() {
+				continue
+			}
+			changes = append(changes, Change{
+				Section: name,
+				Key:     key,
+				Old:     value(key, before.Field(j)),
+				New:     value(key, after.Field(j)),
+				Restart: restart(name, key),
+			})
+		}
+	}
+	return changes
+}
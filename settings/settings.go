@@ -1,16 +1,14 @@
 package settings
 
-import (
-	gcfg "gopkg.in/gcfg.v1"
-)
-
 type server struct {
 	Items serverItems `gcfg:"server"`
 }
 
 type serverItems struct {
-	RunMode string `gcfg:"RunMode"`
-	Port    int    `gcfg:"Port"`
+	RunMode      string `gcfg:"RunMode"`
+	Port         int    `gcfg:"Port"`
+	Services     string `gcfg:"Services"`
+	DrainTimeout int    `gcfg:"DrainTimeout"`
 }
 
 type gRPCUsersInfo struct {
@@ -18,19 +16,255 @@ type gRPCUsersInfo struct {
 }
 
 type gRPCUsersInfoItems struct {
-	Host string `gcfg:"Host"`
-	Port int    `gcfg:"Port"`
+	Host            string `gcfg:"Host"`
+	Port            int    `gcfg:"Port"`
+	Timeout         int    `gcfg:"Timeout"`
+	Retries         int    `gcfg:"Retries"`
+	RetryBackoff    int    `gcfg:"RetryBackoff"`
+	BreakerFailures int    `gcfg:"BreakerFailures"`
+	BreakerCooldown int    `gcfg:"BreakerCooldown"`
+	BreakerPolicy   string `gcfg:"BreakerPolicy"`
+	LastGoodTTL     int    `gcfg:"LastGoodTTL"`
+	HealthCheck     bool   `gcfg:"HealthCheck"`
+	CAFile          string `gcfg:"CAFile"`
+	CertFile        string `gcfg:"CertFile"`
+	KeyFile         string `gcfg:"KeyFile"`
+	ServerName      string `gcfg:"ServerName"`
+	Insecure        bool   `gcfg:"Insecure"`
+	PlayAuth        bool   `gcfg:"PlayAuth"`
+}
+
+type record struct {
+	Items recordItems `gcfg:"record"`
+}
+
+type recordItems struct {
+	Enabled     bool   `gcfg:"Enabled"`
+	Apps        string `gcfg:"Apps"`
+	Path        string `gcfg:"Path"`
+	FileName    string `gcfg:"FileName"`
+	MaxSize     int64  `gcfg:"MaxSize"`
+	MaxDuration int    `gcfg:"MaxDuration"`
+	Format      string `gcfg:"Format"`
+	FastStart   bool   `gcfg:"FastStart"`
+}
+
+type admin struct {
+	Items adminItems `gcfg:"admin"`
+}
+
+type adminItems struct {
+	Enabled bool   `gcfg:"Enabled"`
+	Host    string `gcfg:"Host"`
+	Port    int    `gcfg:"Port"`
+	Token   string `gcfg:"Token"`
+	// the files of the sources api are only read from this directory
+	SourcesDir string `gcfg:"SourcesDir"`
+}
+
+type fallback struct {
+	Items fallbackItems `gcfg:"fallback"`
+}
+
+type fallbackItems struct {
+	GracePeriod int    `gcfg:"GracePeriod"`
+	Slate       string `gcfg:"Slate"`
+}
+
+type failover struct {
+	Items failoverItems `gcfg:"failover"`
+}
+
+type failoverItems struct {
+	Enabled      bool   `gcfg:"Enabled"`
+	BackupSuffix string `gcfg:"BackupSuffix"`
+	StallTimeout int    `gcfg:"StallTimeout"`
+}
+
+type auth struct {
+	Items authItems `gcfg:"auth"`
+}
+
+type authItems struct {
+	Backend        string `gcfg:"Backend"`
+	File           string `gcfg:"File"`
+	WebhookURL     string `gcfg:"WebhookURL"`
+	WebhookTimeout int    `gcfg:"WebhookTimeout"`
+	CacheTTL       int    `gcfg:"CacheTTL"`
+	NegativeTTL    int    `gcfg:"NegativeTTL"`
+}
+
+type token struct {
+	Items tokenItems `gcfg:"token"`
+}
+
+type tokenItems struct {
+	Enabled  bool   `gcfg:"Enabled"`
+	Secret   string `gcfg:"Secret"`
+	Required bool   `gcfg:"Required"`
+}
+
+type events struct {
+	Items eventsItems `gcfg:"events"`
+}
+
+type eventsItems struct {
+	Report bool `gcfg:"Report"`
+	Buffer int  `gcfg:"Buffer"`
+}
+
+type webhooks struct {
+	Items webhooksItems `gcfg:"webhooks"`
+}
+
+type webhooksItems struct {
+	URLs         string `gcfg:"URLs"`
+	Events       string `gcfg:"Events"`
+	Secret       string `gcfg:"Secret"`
+	Timeout      int    `gcfg:"Timeout"`
+	Retries      int    `gcfg:"Retries"`
+	RetryBackoff int    `gcfg:"RetryBackoff"`
+	Queue        int    `gcfg:"Queue"`
+}
+
+type control struct {
+	Items controlItems `gcfg:"control"`
+}
+
+type controlItems struct {
+	Enabled  bool   `gcfg:"Enabled"`
+	Host     string `gcfg:"Host"`
+	Port     int    `gcfg:"Port"`
+	CertFile string `gcfg:"CertFile"`
+	KeyFile  string `gcfg:"KeyFile"`
+	// clients must have a certificate signed by one of these
+	ClientCAFile string `gcfg:"ClientCAFile"`
 }
 
-// ServerSettings Holds datas for settings in conf/conf.ini in server section
-var ServerSettings server
+type logs struct {
+	Items logsItems `gcfg:"log"`
+}
+
+type logsItems struct {
+	Format         string `gcfg:"Format"`
+	Level          string `gcfg:"Level"`
+	HandshakeLevel string `gcfg:"HandshakeLevel"`
+	ChunkLevel     string `gcfg:"ChunkLevel"`
+	AMFLevel       string `gcfg:"AMFLevel"`
+	RestreamLevel  string `gcfg:"RestreamLevel"`
+	GRPCLevel      string `gcfg:"GRPCLevel"`
+	File           string `gcfg:"File"`
+	AccessFile     string `gcfg:"AccessFile"`
+	MaxSize        int64  `gcfg:"MaxSize"`
+	RotateInterval int    `gcfg:"RotateInterval"`
+	MaxFiles       int    `gcfg:"MaxFiles"`
+}
+
+type limits struct {
+	Items limitsItems `gcfg:"limits"`
+}
+
+type limitsItems struct {
+	MaxConnections   int     `gcfg:"MaxConnections"`
+	MaxPublishers    int     `gcfg:"MaxPublishers"`
+	MaxPlayers       int     `gcfg:"MaxPlayers"`
+	RateLimit        float64 `gcfg:"RateLimit"`
+	RateBurst        int     `gcfg:"RateBurst"`
+	HandshakeTimeout int     `gcfg:"HandshakeTimeout"`
+	CommandTimeout   int     `gcfg:"CommandTimeout"`
+	IdleTimeout      int     `gcfg:"IdleTimeout"`
+	WriteTimeout     int     `gcfg:"WriteTimeout"`
+	KeepAlive        int     `gcfg:"KeepAlive"`
+}
+
+type acl struct {
+	Items aclItems `gcfg:"acl"`
+}
+
+type aclItems struct {
+	PublishAllow string `gcfg:"PublishAllow"`
+	PublishDeny  string `gcfg:"PublishDeny"`
+	PlayAllow    string `gcfg:"PlayAllow"`
+	PlayDeny     string `gcfg:"PlayDeny"`
+	AdminAllow   string `gcfg:"AdminAllow"`
+	AdminDeny    string `gcfg:"AdminDeny"`
+}
+
+// ServerSettings returns the datas for settings in conf/conf.ini in server section
+func ServerSettings() server {
+	return *find[server](sections())
+}
+
+// GRPCUsersInfoSettings returns the datas for settings in conf/conf.ini in grpc_usersinfo section
+func GRPCUsersInfoSettings() gRPCUsersInfo {
+	return *find[gRPCUsersInfo](sections())
+}
+
+// RecordSettings returns the datas for settings in conf/conf.ini in record section
+func RecordSettings() record {
+	return *find[record](sections())
+}
+
+// AdminSettings returns the datas for settings in conf/conf.ini in admin section
+func AdminSettings() admin {
+	return *find[admin](sections())
+}
+
+// FallbackSettings returns the datas for settings in conf/conf.ini in fallback section
+func FallbackSettings() fallback {
+	return *find[fallback](sections())
+}
+
+// FailoverSettings returns the datas for settings in conf/conf.ini in failover section
+func FailoverSettings() failover {
+	return *find[failover](sections())
+}
+
+// AuthSettings returns the datas for settings in conf/conf.ini in auth section
+func AuthSettings() auth {
+	return *find[auth](sections())
+}
+
+// TokenSettings returns the datas for settings in conf/conf.ini in token section
+func TokenSettings() token {
+	return *find[token](sections())
+}
+
+// EventsSettings returns the datas for settings in conf/conf.ini in events section
+func EventsSettings() events {
+	return *find[events](sections())
+}
+
+// WebhooksSettings returns the datas for settings in conf/conf.ini in webhooks section
+func WebhooksSettings() webhooks {
+	return *find[webhooks](sections())
+}
+
+// ControlSettings returns the datas for settings in conf/conf.ini in control section
+func ControlSettings() control {
+	return *find[control](sections())
+}
+
+// LogSettings returns the datas for settings in conf/conf.ini in log section
+func LogSettings() logs {
+	return *find[logs](sections())
+}
+
+// LimitsSettings returns the datas for settings in conf/conf.ini in limits section
+func LimitsSettings() limits {
+	return *find[limits](sections())
+}
+
+// ACLSettings returns the datas for settings in conf/conf.ini in acl section
+func ACLSettings() acl {
+	return *find[acl](sections())
+}
 
-// GRPCUsersInfoSettings Holds datas for settings in conf/conf.ini in grpc_usersinfo section
-var GRPCUsersInfoSettings gRPCUsersInfo
+// DefaultPath is the config file which is used without the -config flag
+const DefaultPath = "./conf/conf.ini"
 
 // SetUp imports settings data from configure file to corresponding global variables
 // that are defined in this package
-func SetUp() {
-	gcfg.ReadFileInto(&ServerSettings, "./conf/conf.ini")
-	gcfg.ReadFileInto(&GRPCUsersInfoSettings, "./conf/conf.ini")
+func SetUp(path string) error {
+	return Load(path)
 }
@@ -0,0 +1,257 @@
+package settings
+
+import (
+	"fmt"
+	"net"
+	"net/url"
+	"os"
+	"strings"
+)
+
+// Problems are the mistakes found in a config file, one per line
+type Problems []string
+
+func (p Problems) Error() string {
+	return strings.Join(p, "\n")
+}
+
+// checker collects the problems of the sections
+type checker struct {
+	problems Problems
+}
+
+func (c *checker) add(section, format string, args ...interface{}) {
+	c.problems = append(c.problems, fmt.Sprintf("[%s] ", section)+fmt.Sprintf(format, args...))
+}
+
+func (c *checker) port(section, key string, port int) {
+	if port < 1 || port > 65535 {
+		c.add(section, "%s %d is not a valid port", key, port)
+	}
+}
+
+func (c *checker) host(section, key, host string) {
+	if host == "" || strings.ContainsAny(host, " /") || strings.Contains(host, ":") && net.ParseIP(host) == nil {
+		c.add(section, "%s %q is not a valid host", key, host)
+	}
+}
+
+func (c *checker) file(section, key, path string) {
+	if path == "" {
+		c.add(section, "%s is required", key)
+		return
+	}
+	if info, err := os.Stat(path); err != nil {
+		c.add(section, "%s %s", key, err.Error())
+	} else if info.IsDir() {
+		c.add(section, "%s %s is a directory", key, path)
+	}
+}
+
+func (c *checker) nonNegative(section, key string, value int64) {
+	if value < 0 {
+		c.add(section, "%s %d can't be negative", key, value)
+	}
+}
+
+func (c *checker) oneOf(section, key, value string, allowed ...string) {
+	for _, a := range allowed {
+		if value == a {
+			return
+		}
+	}
+	c.add(section, "%s %q must be one of %s", key, value, strings.Join(allowed, ", "))
+}
+
+// rules checks comma separated cidrs or ips which may start with app=
+func (c *checker) rules(section, key, list string, apps bool) {
+	for _, r := range strings.Split(list, ",") {
+		r = strings.TrimSpace(r)
+		if r == "" {
+			continue
+		}
+		if i := strings.Index(r, "="); i >= 0 {
+			if !apps {
+				c.add(section, "%s %q can't have an app", key, r)
+				continue
+			}
+			r = strings.TrimSpace(r[i+1:])
+		}
+		if _, _, err := net.ParseCIDR(r); err != nil && net.ParseIP(r) == nil {
+			c.add(section, "%s %q is not a valid cidr or ip", key, r)
+		}
+	}
+}
+
+func (c *checker) url(section, key, value string) {
+	u, err := url.Parse(value)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		c.add(section, "%s %q is not a valid http url", key, value)
+	}
+}
+
+// Validate checks the loaded settings and returns every problem it finds
+func Validate() Problems {
+	return validate(sections())
+}
+
+// validate checks the sections of set
+// sections which are disabled are only checked for their numbers
+func validate(set []interface{}) Problems {
+	c := &checker{}
+
+	server := find[server](set).Items
+	c.oneOf("server", "RunMode", server.RunMode, "Debug", "Release")
+	c.port("server", "Port", server.Port)
+	c.file("server", "Services", server.Services)
+	c.nonNegative("server", "DrainTimeout", int64(server.DrainTimeout))
+
+	auth := find[auth](set).Items
+	c.oneOf("auth", "Backend", auth.Backend, "grpc", "file", "webhook", "none")
+	c.nonNegative("auth", "WebhookTimeout", int64(auth.WebhookTimeout))
+	c.nonNegative("auth", "CacheTTL", int64(auth.CacheTTL))
+	c.nonNegative("auth", "NegativeTTL", int64(auth.NegativeTTL))
+	switch auth.Backend {
+	case "file":
+		c.file("auth", "File", auth.File)
+	case "webhook":
+		c.url("auth", "WebhookURL", auth.WebhookURL)
+	}
+
+	grpc := find[gRPCUsersInfo](set).Items
+	c.nonNegative("grpcusersinfo", "Timeout", int64(grpc.Timeout))
+	c.nonNegative("grpcusersinfo", "Retries", int64(grpc.Retries))
+	c.nonNegative("grpcusersinfo", "RetryBackoff", int64(grpc.RetryBackoff))
+	c.nonNegative("grpcusersinfo", "BreakerFailures", int64(grpc.BreakerFailures))
+	c.nonNegative("grpcusersinfo", "BreakerCooldown", int64(grpc.BreakerCooldown))
+	c.oneOf("grpcusersinfo", "BreakerPolicy", grpc.BreakerPolicy, "deny", "lastgood")
+	if grpc.BreakerPolicy == "lastgood" && grpc.LastGoodTTL <= 0 {
+		c.add("grpcusersinfo", "LastGoodTTL must be above 0 with the lastgood policy")
+	}
+	if auth.Backend == "grpc" || find[events](set).Items.Report {
+		c.host("grpcusersinfo", "Host", grpc.Host)
+		c.port("grpcusersinfo", "Port", grpc.Port)
+		if !grpc.Insecure {
+			if grpc.CAFile != "" {
+				c.file("grpcusersinfo", "CAFile", grpc.CAFile)
+			}
+			if grpc.CertFile != "" || grpc.KeyFile != "" {
+				c.file("grpcusersinfo", "CertFile", grpc.CertFile)
+				c.file("grpcusersinfo", "KeyFile", grpc.KeyFile)
+			}
+		}
+	}
+
+	record := find[record](set).Items
+	c.nonNegative("record", "MaxSize", record.MaxSize)
+	c.nonNegative("record", "MaxDuration", int64(record.MaxDuration))
+	if record.Enabled {
+		if record.Path == "" {
+			c.add("record", "Path is required")
+		} else if info, err := os.Stat(record.Path); err == nil && !info.IsDir() {
+			c.add("record", "Path %s is not a directory", record.Path)
+		}
+		for _, format := range strings.Split(record.Format, ",") {
+			c.oneOf("record", "Format", strings.TrimSpace(format), "flv", "mp4")
+		}
+	}
+
+	admin := find[admin](set).Items
+	if admin.Enabled {
+		c.host("admin", "Host", admin.Host)
+		c.port("admin", "Port", admin.Port)
+		if admin.Token != "" {
+			if admin.SourcesDir == "" {
+				c.add("admin", "SourcesDir is required")
+			} else if info, err := os.Stat(admin.SourcesDir); err == nil && !info.IsDir() {
+				c.add("admin", "SourcesDir %s is not a directory", admin.SourcesDir)
+			}
+		}
+	}
+
+	fallback := find[fallback](set).Items
+	c.nonNegative("fallback", "GracePeriod", int64(fallback.GracePeriod))
+	if fallback.GracePeriod > 0 && fallback.Slate != "" {
+		c.file("fallback", "Slate", fallback.Slate)
+	}
+
+	failover := find[failover](set).Items
+	c.nonNegative("failover", "StallTimeout", int64(failover.StallTimeout))
+	if failover.Enabled && failover.BackupSuffix == "" {
+		c.add("failover", "BackupSuffix is required")
+	}
+
+	if token := find[token](set).Items; token.Enabled && token.Secret == "" {
+		c.add("token", "Secret is required")
+	}
+
+	c.nonNegative("events", "Buffer", int64(find[events](set).Items.Buffer))
+
+	webhooks := find[webhooks](set).Items
+	c.nonNegative("webhooks", "Timeout", int64(webhooks.Timeout))
+	c.nonNegative("webhooks", "Retries", int64(webhooks.Retries))
+	c.nonNegative("webhooks", "RetryBackoff", int64(webhooks.RetryBackoff))
+	c.nonNegative("webhooks", "Queue", int64(webhooks.Queue))
+	for _, u := range strings.Split(webhooks.URLs, ",") {
+		if u = strings.TrimSpace(u); u != "" {
+			c.url("webhooks", "URLs", u)
+		}
+	}
+
+	control := find[control](set).Items
+	if control.Enabled {
+		c.host("control", "Host", control.Host)
+		c.port("control", "Port", control.Port)
+		c.file("control", "CertFile", control.CertFile)
+		c.file("control", "KeyFile", control.KeyFile)
+		c.file("control", "ClientCAFile", control.ClientCAFile)
+	}
+
+	logs := find[logs](set).Items
+	c.oneOf("log", "Format", logs.Format, "logfmt", "json")
+	c.oneOf("log", "Level", logs.Level, "debug", "info", "warning", "error")
+	for _, level := range []struct{ key, value string }{
+		{"HandshakeLevel", logs.HandshakeLevel},
+		{"ChunkLevel", logs.ChunkLevel},
+		{"AMFLevel", logs.AMFLevel},
+		{"RestreamLevel", logs.RestreamLevel},
+		{"GRPCLevel", logs.GRPCLevel},
+	} {
+		// empty ones use Level
+		if level.value != "" {
+			c.oneOf("log", level.key, level.value, "debug", "info", "warning", "error")
+		}
+	}
+	if server.RunMode == "Release" && logs.File == "" {
+		c.add("log", "File is required in Release mode")
+	}
+	c.nonNegative("log", "MaxSize", logs.MaxSize)
+	c.nonNegative("log", "RotateInterval", int64(logs.RotateInterval))
+	c.nonNegative("log", "MaxFiles", int64(logs.MaxFiles))
+
+	limits := find[limits](set).Items
+	c.nonNegative("limits", "MaxConnections", int64(limits.MaxConnections))
+	c.nonNegative("limits", "MaxPublishers", int64(limits.MaxPublishers))
+	c.nonNegative("limits", "MaxPlayers", int64(limits.MaxPlayers))
+	if limits.RateLimit < 0 {
+		c.add("limits", "RateLimit %g can't be negative", limits.RateLimit)
+	}
+	if limits.RateLimit > 0 && limits.RateBurst < 1 {
+		c.add("limits", "RateBurst must be at least 1 when RateLimit is set")
+	}
+	c.nonNegative("limits", "HandshakeTimeout", int64(limits.HandshakeTimeout))
+	c.nonNegative("limits", "CommandTimeout", int64(limits.CommandTimeout))
+	c.nonNegative("limits", "IdleTimeout", int64(limits.IdleTimeout))
+	c.nonNegative("limits", "WriteTimeout", int64(limits.WriteTimeout))
+	c.nonNegative("limits", "KeepAlive", int64(limits.KeepAlive))
+
+	acl := find[acl](set).Items
+	c.rules("acl", "PublishAllow", acl.PublishAllow, true)
+	c.rules("acl", "PublishDeny", acl.PublishDeny, true)
+	c.rules("acl", "PlayAllow", acl.PlayAllow, true)
+	c.rules("acl", "PlayDeny", acl.PlayDeny, true)
+	c.rules("acl", "AdminAllow", acl.AdminAllow, false)
+	c.rules("acl", "AdminDeny", acl.AdminDeny, false)
+
+	return c.problems
+}
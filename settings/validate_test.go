@@ -0,0 +1,111 @@
+package settings
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		name     string
+		conf     string
+		problems []string
+	}{
+		{"defaults", "", nil},
+		{
+			name:     "port",
+			conf:     "[admin]\nEnabled = true\nPort = 70000\n",
+			problems: []string{"[admin] Port 70000 is not a valid port"},
+		},
+		{
+			name:     "negative numbers",
+			conf:     "[server]\nDrainTimeout = -1\n[record]\nMaxSize = -5\n",
+			problems: []string{"[server] DrainTimeout -1 can't be negative", "[record] MaxSize -5 can't be negative"},
+		},
+		{
+			name:     "zero is not negative",
+			conf:     "[server]\nDrainTimeout = 0\n[limits]\nMaxPlayers = 0\n",
+			problems: nil,
+		},
+		{
+			name:     "one of",
+			conf:     "[server]\nRunMode = debug\n[log]\nChunkLevel = trace\n",
+			problems: []string{`[server] RunMode "debug" must be one of Debug, Release`, `[log] ChunkLevel "trace" must be one of debug, info, warning, error`},
+		},
+		{
+			name:     "missing file",
+			conf:     "[auth]\nBackend = file\nFile = /nonexistent/keys.yaml\n",
+			problems: []string{"[auth] File stat /nonexistent/keys.yaml: no such file or directory"},
+		},
+		{
+			name:     "webhook url",
+			conf:     "[auth]\nBackend = webhook\nWebhookURL = ftp://example.com\n[webhooks]\nURLs = https://example.com, example.org\n",
+			problems: []string{`[auth] WebhookURL "ftp://example.com" is not a valid http url`, `[webhooks] URLs "example.org" is not a valid http url`},
+		},
+		{
+			name:     "grpc host",
+			conf:     "[auth]\nBackend = grpc\n[grpcusersinfo]\nHost = users:4005\nInsecure = true\n",
+			problems: []string{`[grpcusersinfo] Host "users:4005" is not a valid host`},
+		},
+		{
+			name:     "lastgood needs a ttl",
+			conf:     "[grpcusersinfo]\nBreakerPolicy = lastgood\nLastGoodTTL = 0\n",
+			problems: []string{"[grpcusersinfo] LastGoodTTL must be above 0 with the lastgood policy"},
+		},
+		{
+			name:     "disabled sections keep their invalid keys",
+			conf:     "[control]\nPort = 0\nCertFile = /nonexistent\n[failover]\nBackupSuffix = \"\"\n",
+			problems: nil,
+		},
+		{
+			name:     "failover suffix",
+			conf:     "[failover]\nEnabled = true\nBackupSuffix = \"\"\n",
+			problems: []string{"[failover] BackupSuffix is required"},
+		},
+		{
+			name:     "token secret",
+			conf:     "[token]\nEnabled = true\n",
+			problems: []string{"[token] Secret is required"},
+		},
+		{
+			name:     "rate burst",
+			conf:     "[limits]\nRateLimit = 2.5\nRateBurst = 0\n",
+			problems: []string{"[limits] RateBurst must be at least 1 when RateLimit is set"},
+		},
+		{
+			name: "acl rules",
+			conf: "[acl]\nPublishAllow = live=10.0.0.0/8, 10.0.0.1, 300.0.0.1\nAdminAllow = live=127.0.0.1\n",
+			problems: []string{
+				`[acl] PublishAllow "300.0.0.1" is not a valid cidr or ip`,
+				`[acl] AdminAllow "live=127.0.0.1" can't have an app`,
+			},
+		},
+		{
+			// writeConf starts the file with 4 lines
+			name: "unknown keys",
+			conf: "[server]\nPrt = 1935\n[recording]\nEnabled = true\n",
+			problems: []string{
+				"conf.ini:6: unknown key Prt in [server]",
+				"conf.ini:7: unknown section [recording]",
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := read(writeConf(t, tt.conf))
+			var problems Problems
+			if err != nil && !errors.As(err, &problems) {
+				t.Fatal(err)
+			}
+			if len(problems) != len(tt.problems) {
+				t.Fatalf("got problems %q, want %q", problems, tt.problems)
+			}
+			for i, want := range tt.problems {
+				if !strings.HasSuffix(problems[i], want) {
+					t.Errorf("problem %d = %q, want %q", i, problems[i], want)
+				}
+			}
+		})
+	}
+}
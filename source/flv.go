@@ -0,0 +1,99 @@
+package source
+
+import (
+	"context"
+	"io"
+	"log"
+	"os"
+	"time"
+
+	"github.com/alipourhabibi/restream/logging"
+	"github.com/nareix/joy4/format/flv"
+	"github.com/praveen001/joy4/format/rtmp"
+)
+
+// PublishFLV pushes the flv file at path to the rtmp url as a live stream
+// packets are sent at the pace of their timestamps and when loop is true
+// the file is restarted at its end with timestamps continuing from the last packet
+// it returns when the file ends, publishing fails or ctx is done
+func PublishFLV(ctx context.Context, log *log.Logger, path, url string, loop bool) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	demuxer := flv.NewDemuxer(file)
+	streams, err := demuxer.Streams()
+	if err != nil {
+		return err
+	}
+
+	conn, err := rtmp.Dial(url)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	if err := conn.WriteHeader(streams); err != nil {
+		return err
+	}
+	log.Printf("publishing %s to %s\n", path, logging.RedactURL(url))
+
+	start := time.Now()
+	// offset is added to the timestamps of every loop so they keep increasing
+	var offset, last time.Duration
+	for {
+		pkt, err := demuxer.ReadPacket()
+		if err == io.EOF && loop {
+			if _, err := file.Seek(0, io.SeekStart); err != nil {
+				return err
+			}
+			demuxer = flv.NewDemuxer(file)
+			if _, err := demuxer.Streams(); err != nil {
+				return err
+			}
+			// leave a frame worth of gap between the loops
+			offset = last + 40*time.Millisecond
+			continue
+		}
+		if err == io.EOF {
+			return conn.WriteTrailer()
+		}
+		if err != nil {
+			return err
+		}
+
+		pkt.Time += offset
+		if pkt.Time > last {
+			last = pkt.Time
+		}
+		if err := wait(ctx, start.Add(pkt.Time)); err != nil {
+			conn.WriteTrailer()
+			return err
+		}
+		if err := conn.WritePacket(pkt); err != nil {
+			return err
+		}
+		// WriteTrailer only flushes the buffered packets
+		// which should be sent now to keep the pace
+		if err := conn.WriteTrailer(); err != nil {
+			return err
+		}
+	}
+}
+
+// wait blocks until t or until ctx is done
+func wait(ctx context.Context, t time.Time) error {
+	d := time.Until(t)
+	if d <= 0 {
+		return ctx.Err()
+	}
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
@@ -0,0 +1,60 @@
+package source
+
+import (
+	"bufio"
+	"io"
+	"os"
+
+	"github.com/nareix/joy4/format/flv/flvio"
+)
+
+// Tag is a flv tag with its payload as it's sent in an rtmp message
+type Tag struct {
+	Type      uint8
+	Timestamp uint32
+	Data      []byte
+}
+
+// ReadTags reads every tag of the flv file at path
+func ReadTags(path string) ([]Tag, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	r := bufio.NewReader(file)
+
+	b := make([]byte, flvio.TagHeaderLength)
+	if _, err := io.ReadFull(r, b[:flvio.FileHeaderLength]); err != nil {
+		return nil, err
+	}
+	_, skip, err := flvio.ParseFileHeader(b)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := r.Discard(skip); err != nil {
+		return nil, err
+	}
+
+	var tags []Tag
+	for {
+		if _, err := io.ReadFull(r, b); err == io.EOF {
+			return tags, nil
+		} else if err != nil {
+			return nil, err
+		}
+		tag, ts, length, err := flvio.ParseTagHeader(b)
+		if err != nil {
+			return nil, err
+		}
+		data := make([]byte, length)
+		if _, err := io.ReadFull(r, data); err != nil {
+			return nil, err
+		}
+		// PreviousTagSize
+		if _, err := r.Discard(flvio.TagTrailerLength); err != nil {
+			return nil, err
+		}
+		tags = append(tags, Tag{Type: tag.Type, Timestamp: uint32(ts), Data: data})
+	}
+}